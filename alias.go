@@ -0,0 +1,193 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnresolvedAlias is returned by an AliasResolver when the alias doesn't belong to any item
+var ErrUnresolvedAlias = errors.New("unresolved alias")
+
+// AliasResolver maps aliases (GTINs, vendor SKUs, legacy codes) to the canonical item codes
+type AliasResolver interface {
+	ResolveAlias(alias string) (string, error)
+}
+
+// BatchAliasResolver is an AliasResolver that can also resolve several aliases in one call
+// Aliases that are not in the returned map are unresolved
+type BatchAliasResolver interface {
+	AliasResolver
+	ResolveAliases(aliases []string) (map[string]string, error)
+}
+
+// AliasResolution is the result of resolving several aliases
+type AliasResolution struct {
+	ItemCodes  map[string]string // item code by alias
+	Unresolved []string
+}
+
+// AliasCache is a transparent cache for an AliasResolver, it remembers aliases for maxAge
+// Unresolved aliases are not cached, so new items are found as soon as the resolver knows them
+type AliasCache struct {
+	resolver AliasResolver
+	maxAge   time.Duration
+	aliases  map[string]*aliasItem
+	mu       *sync.Mutex
+}
+
+// aliasItem is the item stored in the cache with its creation date and the item code of the alias
+type aliasItem struct {
+	dateCreated *time.Time
+	itemCode    string
+}
+
+// NewAliasCache creates a cache for the alias resolver
+func NewAliasCache(resolver AliasResolver, maxAge time.Duration) *AliasCache {
+	return &AliasCache{
+		resolver: resolver,
+		maxAge:   maxAge,
+		aliases:  map[string]*aliasItem{},
+		mu:       &sync.Mutex{},
+	}
+}
+
+// Resolve gets the item code of the alias, it returns ErrUnresolvedAlias if there is none
+func (c *AliasCache) Resolve(alias string) (string, error) {
+	if itemCode, ok := c.cached(alias); ok {
+		return itemCode, nil
+	}
+	itemCode, err := c.resolver.ResolveAlias(alias)
+	if err != nil {
+		return "", fmt.Errorf("resolving alias %v : %w", alias, err)
+	}
+	c.store(alias, itemCode)
+	return itemCode, nil
+}
+
+// ResolveAll resolves several aliases, the ones that are not cached are resolved in one batch call if the resolver
+// supports it, or in parallel if it doesn't
+// Unresolved aliases are reported in the result, any other error of the resolver is returned
+func (c *AliasCache) ResolveAll(aliases ...string) (AliasResolution, error) {
+	resolution := AliasResolution{ItemCodes: map[string]string{}, Unresolved: []string{}}
+	missing := []string{}
+	for _, alias := range aliases {
+		if itemCode, ok := c.cached(alias); ok {
+			resolution.ItemCodes[alias] = itemCode
+		} else {
+			missing = append(missing, alias)
+		}
+	}
+	if len(missing) == 0 {
+		return resolution, nil
+	}
+
+	resolved, err := c.resolveMissing(missing)
+	if err != nil {
+		return AliasResolution{}, err
+	}
+	for _, alias := range missing {
+		itemCode, ok := resolved[alias]
+		if !ok {
+			resolution.Unresolved = append(resolution.Unresolved, alias)
+			continue
+		}
+		c.store(alias, itemCode)
+		resolution.ItemCodes[alias] = itemCode
+	}
+	sort.Strings(resolution.Unresolved)
+	return resolution, nil
+}
+
+func (c *AliasCache) resolveMissing(aliases []string) (map[string]string, error) {
+	if batchResolver, ok := c.resolver.(BatchAliasResolver); ok {
+		resolved, err := batchResolver.ResolveAliases(aliases)
+		if err != nil {
+			return nil, fmt.Errorf("resolving aliases : %v", err.Error())
+		}
+		return resolved, nil
+	}
+	itemCodes := make([]string, len(aliases))
+	errs := make([]error, len(aliases))
+	wg := &sync.WaitGroup{}
+	for i, alias := range aliases {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+			itemCodes[i], errs[i] = c.resolver.ResolveAlias(alias)
+		}(i, alias)
+	}
+	wg.Wait()
+	resolved := map[string]string{}
+	for i, alias := range aliases {
+		if errors.Is(errs[i], ErrUnresolvedAlias) {
+			continue
+		}
+		if errs[i] != nil {
+			return nil, fmt.Errorf("resolving alias %v : %v", alias, errs[i].Error())
+		}
+		resolved[alias] = itemCodes[i]
+	}
+	return resolved, nil
+}
+
+func (c *AliasCache) cached(alias string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.aliases[alias]
+	if !ok || !time.Now().Before(item.dateCreated.Add(c.maxAge)) {
+		return "", false
+	}
+	return item.itemCode, true
+}
+
+func (c *AliasCache) store(alias string, itemCode string) {
+	dateCreated := time.Now()
+	c.mu.Lock()
+	c.aliases[alias] = &aliasItem{dateCreated: &dateCreated, itemCode: itemCode}
+	c.mu.Unlock()
+}
+
+// AliasPrices is the result of getting prices by alias
+type AliasPrices struct {
+	Prices     map[string]float64 // price by alias
+	ItemCodes  map[string]string  // item code by alias
+	Unresolved []string
+}
+
+// GetPricesForAliases resolves the aliases and gets the prices of their items
+// Unresolved aliases are reported and don't make the lookup fail, if any price fails it returns an error
+func GetPricesForAliases(prices *TransparentCache, aliases *AliasCache, aliasList ...string) (AliasPrices, error) {
+	resolution, err := aliases.ResolveAll(aliasList...)
+	if err != nil {
+		return AliasPrices{}, err
+	}
+	// several aliases can be the same item, it is looked up only once
+	itemCodes := []string{}
+	seen := map[string]bool{}
+	for _, itemCode := range resolution.ItemCodes {
+		if !seen[itemCode] {
+			seen[itemCode] = true
+			itemCodes = append(itemCodes, itemCode)
+		}
+	}
+	priceItems, errs := prices.getPriceItems(DefaultCaller, itemCodes)
+	itemPrices := map[string]float64{}
+	for i, itemCode := range itemCodes {
+		if errs[i] != nil {
+			return AliasPrices{}, errs[i]
+		}
+		itemPrices[itemCode] = priceItems[i].price
+	}
+	result := AliasPrices{
+		Prices:     map[string]float64{},
+		ItemCodes:  resolution.ItemCodes,
+		Unresolved: resolution.Unresolved,
+	}
+	for alias, itemCode := range resolution.ItemCodes {
+		result.Prices[alias] = itemPrices[itemCode]
+	}
+	return result, nil
+}
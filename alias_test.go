@@ -0,0 +1,105 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type mockAliasResolver struct {
+	numCalls int
+	aliases  map[string]string
+}
+
+func (m *mockAliasResolver) ResolveAlias(alias string) (string, error) {
+	m.numCalls++
+	itemCode, ok := m.aliases[alias]
+	if !ok {
+		return "", ErrUnresolvedAlias
+	}
+	return itemCode, nil
+}
+
+// mockBatchAliasResolver records the batches it was called with
+type mockBatchAliasResolver struct {
+	*mockAliasResolver
+	batches [][]string
+}
+
+func (m *mockBatchAliasResolver) ResolveAliases(aliases []string) (map[string]string, error) {
+	m.batches = append(m.batches, aliases)
+	resolved := map[string]string{}
+	for _, alias := range aliases {
+		if itemCode, ok := m.aliases[alias]; ok {
+			resolved[alias] = itemCode
+		}
+	}
+	return resolved, nil
+}
+
+// Check that aliases are cached and unresolved ones are reported
+func TestAliasCache_ResolvesAndCaches(t *testing.T) {
+	resolver := &mockAliasResolver{aliases: map[string]string{"7790001": "p1"}}
+	aliases := NewAliasCache(resolver, time.Minute)
+
+	itemCode, err := aliases.Resolve("7790001")
+	if err != nil || itemCode != "p1" {
+		t.Fatal("wrong resolution", itemCode, err)
+	}
+	aliases.Resolve("7790001")
+	assertInt(t, 1, resolver.numCalls, "wrong number of resolver calls")
+	if _, err := aliases.Resolve("unknown"); !errors.Is(err, ErrUnresolvedAlias) {
+		t.Error("expected unresolved alias error, got", err)
+	}
+}
+
+// Check that batch resolution resolves only the missing aliases in one call and prices are returned by alias
+func TestGetPricesForAliases_ResolvesInBatch(t *testing.T) {
+	priceService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	resolver := &mockBatchAliasResolver{
+		mockAliasResolver: &mockAliasResolver{aliases: map[string]string{
+			"7790001": "p1",
+			"SKU-B":   "p2",
+			"OLD-1":   "p1",
+		}},
+	}
+	prices := NewTransparentCache(priceService, time.Minute)
+	aliases := NewAliasCache(resolver, time.Minute)
+	aliases.Resolve("7790001")
+
+	result, err := GetPricesForAliases(prices, aliases, "7790001", "SKU-B", "OLD-1", "unknown")
+	if err != nil {
+		t.Fatal("error getting prices", err)
+	}
+	if len(resolver.batches) != 1 || len(resolver.batches[0]) != 3 {
+		t.Error("wrong batches", resolver.batches)
+	}
+	if result.Prices["7790001"] != 5 || result.Prices["SKU-B"] != 7 || result.Prices["OLD-1"] != 5 {
+		t.Error("wrong prices returned", result.Prices)
+	}
+	if len(result.Unresolved) != 1 || result.Unresolved[0] != "unknown" {
+		t.Error("wrong unresolved aliases", result.Unresolved)
+	}
+	assertInt(t, 2, priceService.getNumCalls(), "wrong number of service calls")
+}
+
+// errorAliasResolver fails for every alias
+type errorAliasResolver struct{}
+
+func (errorAliasResolver) ResolveAlias(alias string) (string, error) {
+	return "", fmt.Errorf("some error")
+}
+
+// Check that resolver errors other than unresolved aliases are returned
+func TestAliasCache_ReturnsResolverErrors(t *testing.T) {
+	aliases := NewAliasCache(errorAliasResolver{}, time.Minute)
+	if _, err := aliases.ResolveAll("a1", "a2"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,137 @@
+package sample1
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Attributes is the metadata of an item, like its family or brand, by attribute name
+type Attributes map[string]string
+
+// AttributedPriceService is a PriceService that can also return the attributes of the item with its price
+// If the actual service implements it, the cache indexes the attributes on every fill
+type AttributedPriceService interface {
+	PriceService
+	GetPriceWithAttributesFor(itemCode string) (float64, Attributes, error)
+}
+
+// CatalogLoader loads the attributes of the items from an external catalog
+type CatalogLoader interface {
+	LoadAttributes() (map[string]Attributes, error)
+}
+
+// attributeIndex keeps the attributes of each item and the items for each attribute value
+// Attributes are metadata, they are kept when the price of the item is invalidated
+type attributeIndex struct {
+	attributes map[string]Attributes
+	index      map[string]map[string]map[string]bool // name -> value -> item codes
+	mu         *sync.Mutex
+}
+
+func newAttributeIndex() *attributeIndex {
+	return &attributeIndex{
+		attributes: map[string]Attributes{},
+		index:      map[string]map[string]map[string]bool{},
+		mu:         &sync.Mutex{},
+	}
+}
+
+// set replaces the attributes of the item, removing it from the values it doesn't have anymore
+func (a *attributeIndex) set(itemCode string, attributes Attributes) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name, value := range a.attributes[itemCode] {
+		delete(a.index[name][value], itemCode)
+		if len(a.index[name][value]) == 0 {
+			delete(a.index[name], value)
+		}
+	}
+	copied := Attributes{}
+	for name, value := range attributes {
+		copied[name] = value
+		if a.index[name] == nil {
+			a.index[name] = map[string]map[string]bool{}
+		}
+		if a.index[name][value] == nil {
+			a.index[name][value] = map[string]bool{}
+		}
+		a.index[name][value][itemCode] = true
+	}
+	a.attributes[itemCode] = copied
+}
+
+func (a *attributeIndex) get(itemCode string) Attributes {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	attributes := Attributes{}
+	for name, value := range a.attributes[itemCode] {
+		attributes[name] = value
+	}
+	return attributes
+}
+
+// itemCodes returns the codes of the items with the attribute value, sorted
+func (a *attributeIndex) itemCodes(name string, value string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	itemCodes := make([]string, 0, len(a.index[name][value]))
+	for itemCode := range a.index[name][value] {
+		itemCodes = append(itemCodes, itemCode)
+	}
+	sort.Strings(itemCodes)
+	return itemCodes
+}
+
+// SetAttributes sets the attributes of the item, replacing the ones it had
+func (c *TransparentCache) SetAttributes(itemCode string, attributes Attributes) {
+	c.attributes.set(itemCode, attributes)
+}
+
+// AttributesFor returns the attributes known for the item
+func (c *TransparentCache) AttributesFor(itemCode string) Attributes {
+	return c.attributes.get(itemCode)
+}
+
+// LoadCatalog sets the attributes of all the items returned by the loader
+func (c *TransparentCache) LoadCatalog(loader CatalogLoader) error {
+	catalog, err := loader.LoadAttributes()
+	if err != nil {
+		return fmt.Errorf("loading catalog : %v", err.Error())
+	}
+	for itemCode, attributes := range catalog {
+		c.attributes.set(itemCode, attributes)
+	}
+	return nil
+}
+
+// ItemCodesBy returns the codes of the items with the attribute value, sorted
+func (c *TransparentCache) ItemCodesBy(name string, value string) []string {
+	return c.attributes.itemCodes(name, value)
+}
+
+// GetPricesBy gets the prices of all the items with the attribute value, by item code
+// Items that are not cached are filled from the actual service, if any of them fails it returns an error
+func (c *TransparentCache) GetPricesBy(name string, value string) (map[string]float64, error) {
+	itemCodes := c.ItemCodesBy(name, value)
+	priceItems, errs := c.getPriceItems(DefaultCaller, itemCodes)
+	prices := make(map[string]float64, len(itemCodes))
+	for i, itemCode := range itemCodes {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		prices[itemCode] = priceItems[i].price
+	}
+	return prices, nil
+}
+
+// InvalidateBy invalidates all the items with the attribute value, it returns how many were in the cache
+func (c *TransparentCache) InvalidateBy(name string, value string) int {
+	invalidated := 0
+	for _, itemCode := range c.ItemCodesBy(name, value) {
+		if c.Invalidate(itemCode) {
+			invalidated++
+		}
+	}
+	return invalidated
+}
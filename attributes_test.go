@@ -0,0 +1,82 @@
+package sample1
+
+import (
+	"testing"
+	"time"
+)
+
+// mockAttributedPriceService returns the attributes of the items with their prices
+type mockAttributedPriceService struct {
+	*mockPriceService
+	attributes map[string]Attributes
+}
+
+func (m *mockAttributedPriceService) GetPriceWithAttributesFor(itemCode string) (float64, Attributes, error) {
+	price, err := m.GetPriceFor(itemCode)
+	return price, m.attributes[itemCode], err
+}
+
+type mockCatalogLoader map[string]Attributes
+
+func (m mockCatalogLoader) LoadAttributes() (map[string]Attributes, error) {
+	return m, nil
+}
+
+// Check that items loaded from a catalog can be looked up and invalidated by attribute
+func TestGetPricesBy_UsesCatalogAttributes(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+			"p3": {price: 9, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	err := cache.LoadCatalog(mockCatalogLoader{
+		"p1": {"family": "cheese"},
+		"p2": {"family": "cheese"},
+		"p3": {"family": "wine"},
+	})
+	if err != nil {
+		t.Fatal("error loading catalog", err)
+	}
+	prices, err := cache.GetPricesBy("family", "cheese")
+	if err != nil {
+		t.Fatal("error getting prices", err)
+	}
+	if len(prices) != 2 || prices["p1"] != 5 || prices["p2"] != 7 {
+		t.Error("wrong prices returned", prices)
+	}
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+
+	assertInt(t, 2, cache.InvalidateBy("family", "cheese"), "wrong number of invalidated items")
+	assertInt(t, 0, cache.InvalidateBy("family", "wine"), "wrong number of invalidated items")
+	getPriceWithNoErr(t, cache, "p1")
+	assertInt(t, 3, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that attributes returned by the upstream are indexed and replace the old ones
+func TestGetPriceFor_IndexesUpstreamAttributes(t *testing.T) {
+	mockService := &mockAttributedPriceService{
+		mockPriceService: &mockPriceService{
+			mockResults: map[string]mockResult{
+				"p1": {price: 5, err: nil},
+			},
+		},
+		attributes: map[string]Attributes{
+			"p1": {"family": "cheese", "brand": "b1"},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPriceWithNoErr(t, cache, "p1")
+	if codes := cache.ItemCodesBy("brand", "b1"); len(codes) != 1 || codes[0] != "p1" {
+		t.Error("wrong item codes for brand", codes)
+	}
+
+	mockService.attributes["p1"] = Attributes{"family": "cheese", "brand": "b2"}
+	cache.Refresh("p1")
+	assertInt(t, 0, len(cache.ItemCodesBy("brand", "b1")), "old attribute value still indexed")
+	if cache.AttributesFor("p1")["brand"] != "b2" {
+		t.Error("wrong attributes", cache.AttributesFor("p1"))
+	}
+}
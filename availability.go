@@ -0,0 +1,118 @@
+package sample1
+
+import "time"
+
+// Availability is the stock of an item
+type Availability struct {
+	Quantity int
+}
+
+// InStock tells if there is at least one item available
+func (a Availability) InStock() bool {
+	return a.Quantity > 0
+}
+
+// AvailabilityService is a service that we can use to get the stock of the items
+// Like PriceService, calls to this service are expensive
+type AvailabilityService interface {
+	GetAvailabilityFor(itemCode string) (Availability, error)
+}
+
+// AvailabilityCache is a transparent cache for an AvailabilityService
+// It is a TransparentCache underneath, so it has the same semantics and options, usually with a much shorter maxAge
+type AvailabilityCache struct {
+	cache *TransparentCache
+}
+
+// NewAvailabilityCache creates a cache for the availability service
+func NewAvailabilityCache(service AvailabilityService, maxAge time.Duration, opts ...Option) *AvailabilityCache {
+	return &AvailabilityCache{
+		cache: NewTransparentCache(&availabilityAdapter{service: service}, maxAge, append([]Option{withItemKind("availability")}, opts...)...),
+	}
+}
+
+// GetAvailabilityFor gets the availability for the item, either from the cache or the actual service
+func (c *AvailabilityCache) GetAvailabilityFor(itemCode string) (Availability, error) {
+	quantity, err := c.cache.GetPriceFor(itemCode)
+	if err != nil {
+		return Availability{}, err
+	}
+	return Availability{Quantity: int(quantity)}, nil
+}
+
+// GetAvailabilitiesFor gets the availability for several items in parallel, in the same order as the item codes
+// If any of them fails it returns an error
+func (c *AvailabilityCache) GetAvailabilitiesFor(itemCodes ...string) ([]Availability, error) {
+	items, errs := c.cache.getPriceItems(DefaultCaller, itemCodes)
+	availabilities := make([]Availability, len(itemCodes))
+	for i := range itemCodes {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		availabilities[i] = Availability{Quantity: int(items[i].price)}
+	}
+	return availabilities, nil
+}
+
+// Invalidate removes the item from the cache, it returns if the item was in the cache
+func (c *AvailabilityCache) Invalidate(itemCode string) bool {
+	return c.cache.Invalidate(itemCode)
+}
+
+// availabilityAdapter is the PriceService of the TransparentCache, the quantity is stored as the price
+type availabilityAdapter struct {
+	service AvailabilityService
+}
+
+func (a *availabilityAdapter) GetPriceFor(itemCode string) (float64, error) {
+	availability, err := a.service.GetAvailabilityFor(itemCode)
+	if err != nil {
+		return 0, err
+	}
+	return float64(availability.Quantity), nil
+}
+
+// ProductInfo is the price and availability of an item
+type ProductInfo struct {
+	ItemCode     string
+	Price        float64
+	Availability Availability
+}
+
+// GetProductsFor gets the prices and availability of the items in one parallel call, in the same order as the item codes
+// If any of the lookups fails it returns an error
+func GetProductsFor(prices *TransparentCache, availability *AvailabilityCache, itemCodes ...string) ([]ProductInfo, error) {
+	var priceItems []*PriceItem
+	var priceErrs []error
+	var availabilities []Availability
+	var availabilityErr error
+	done := make(chan struct{})
+	go func() {
+		priceItems, priceErrs = prices.getPriceItems(DefaultCaller, itemCodes)
+		done <- struct{}{}
+	}()
+	go func() {
+		availabilities, availabilityErr = availability.GetAvailabilitiesFor(itemCodes...)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+
+	for _, err := range priceErrs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if availabilityErr != nil {
+		return nil, availabilityErr
+	}
+	products := make([]ProductInfo, len(itemCodes))
+	for i, itemCode := range itemCodes {
+		products[i] = ProductInfo{
+			ItemCode:     itemCode,
+			Price:        priceItems[i].price,
+			Availability: availabilities[i],
+		}
+	}
+	return products, nil
+}
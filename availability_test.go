@@ -0,0 +1,90 @@
+package sample1
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type mockAvailabilityService struct {
+	numCalls       int32 // updated atomically, lookups of different items call the service in parallel
+	callDelay      time.Duration
+	availabilities map[string]Availability
+}
+
+func (m *mockAvailabilityService) GetAvailabilityFor(itemCode string) (Availability, error) {
+	atomic.AddInt32(&m.numCalls, 1)
+	time.Sleep(m.callDelay)
+	availability, ok := m.availabilities[itemCode]
+	if !ok {
+		return Availability{}, fmt.Errorf("unknown item %v", itemCode)
+	}
+	return availability, nil
+}
+
+// Check that availability is cached and expired with its own maxAge
+func TestAvailabilityCache_CachesAndExpires(t *testing.T) {
+	mockService := &mockAvailabilityService{
+		availabilities: map[string]Availability{
+			"p1": {Quantity: 3},
+			"p2": {Quantity: 0},
+		},
+	}
+	cache := NewAvailabilityCache(mockService, 50*time.Millisecond)
+	availabilities, err := cache.GetAvailabilitiesFor("p1", "p2")
+	if err != nil {
+		t.Fatal("error getting availabilities", err)
+	}
+	if !availabilities[0].InStock() || availabilities[1].InStock() {
+		t.Error("wrong availabilities returned", availabilities)
+	}
+	cache.GetAvailabilityFor("p1")
+	assertInt(t, 2, int(atomic.LoadInt32(&mockService.numCalls)), "wrong number of service calls")
+	time.Sleep(60 * time.Millisecond)
+	availability, _ := cache.GetAvailabilityFor("p1")
+	assertInt(t, 3, availability.Quantity, "wrong quantity returned")
+	assertInt(t, 3, int(atomic.LoadInt32(&mockService.numCalls)), "wrong number of service calls")
+}
+
+// Check that prices and availability are fetched in parallel and returned in order
+func TestGetProductsFor_CombinesInParallel(t *testing.T) {
+	priceService := &mockPriceService{
+		callDelay: 100 * time.Millisecond,
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+			"p3": {price: 9, err: nil},
+		},
+	}
+	availabilityService := &mockAvailabilityService{
+		callDelay: 100 * time.Millisecond,
+		availabilities: map[string]Availability{
+			"p1": {Quantity: 3},
+			"p2": {Quantity: 1},
+		},
+	}
+	prices := NewTransparentCache(priceService, time.Minute)
+	availability := NewAvailabilityCache(availabilityService, time.Second)
+
+	start := time.Now()
+	products, err := GetProductsFor(prices, availability, "p2", "p1")
+	if err != nil {
+		t.Fatal("error getting products", err)
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Error("calls took too long, expected them to run in parallel")
+	}
+	if products[0].ItemCode != "p2" || products[0].Price != 7 || products[0].Availability.Quantity != 1 {
+		t.Error("wrong product returned", products[0])
+	}
+	if products[1].ItemCode != "p1" || products[1].Price != 5 || products[1].Availability.Quantity != 3 {
+		t.Error("wrong product returned", products[1])
+	}
+
+	// p3 has a price but no availability
+	_, err = GetProductsFor(prices, availability, "p1", "p3")
+	if err == nil || err.Error() != "getting availability from service : unknown item p3" {
+		t.Error("wrong error returned", err)
+	}
+}
@@ -0,0 +1,220 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxBundleAttempts is how many times a bundle is computed when its components keep changing while it is computed
+const maxBundleAttempts = 3
+
+// ErrBundleCycle is returned when registering a bundle that would end up being a component of itself
+var ErrBundleCycle = errors.New("bundle cycle")
+
+// BundleFormula computes the price of a bundle from the prices of its components, in the order they were registered
+type BundleFormula func(componentPrices []float64) float64
+
+// bundle is an item whose price is derived from other items
+type bundle struct {
+	components []string
+	formula    BundleFormula
+}
+
+// RegisterBundle makes the price of bundleCode be computed with formula from the prices of the components
+// Components can be bundles too, as long as no bundle ends up being a component of itself
+// When a component is stored again the bundle is recomputed, when it is invalidated the bundle is invalidated too
+func (c *TransparentCache) RegisterBundle(bundleCode string, components []string, formula BundleFormula) error {
+	c.mu.Lock()
+	visited := map[string]bool{}
+	for _, component := range components {
+		if c.reaches(component, bundleCode, visited) {
+			c.mu.Unlock()
+			return fmt.Errorf("registering bundle %v : %v depends on it : %w", bundleCode, component, ErrBundleCycle)
+		}
+	}
+	if old, ok := c.bundles[bundleCode]; ok {
+		for _, component := range old.components {
+			c.dependents[component] = without(c.dependents[component], bundleCode)
+		}
+	}
+	c.bundles[bundleCode] = &bundle{components: append([]string{}, components...), formula: formula}
+	for _, component := range components {
+		c.dependents[component] = append(without(c.dependents[component], bundleCode), bundleCode)
+	}
+	delete(c.prices, bundleCode)
+	c.mu.Unlock()
+	c.componentInvalidated(bundleCode)
+	return nil
+}
+
+// reaches tells if target is from or one of the components under from, holding the lock
+// visited has the items already known not to reach target, so shared components are only walked once
+func (c *TransparentCache) reaches(from string, target string, visited map[string]bool) bool {
+	if from == target {
+		return true
+	}
+	if visited[from] {
+		return false
+	}
+	visited[from] = true
+	bundle, ok := c.bundles[from]
+	if !ok {
+		return false
+	}
+	for _, component := range bundle.components {
+		if c.reaches(component, target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TransparentCache) bundle(itemCode string) (*bundle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bundle, ok := c.bundles[itemCode]
+	return bundle, ok
+}
+
+// computeBundle gets the components, filling the ones that are not cached on behalf of caller, and stores the bundle price
+func (c *TransparentCache) computeBundle(caller string, bundleCode string, bundle *bundle, trace *LookupTrace) (*PriceItem, error) {
+	trace.rule(fmt.Sprintf("%v is a bundle, computed from %v", bundleCode, bundle.components))
+	componentItems, errs := c.getPriceItems(caller, bundle.components)
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("getting component %v of bundle %v : %v", bundle.components[i], bundleCode, err.Error())
+		}
+	}
+	return c.storeBundle(bundleCode, bundle, componentItems), nil
+}
+
+// storeBundle computes the bundle from the component items and stores it if none of them changed meanwhile
+// The formula runs without the lock, so a slow formula or one that reads the cache doesn't block it
+// If a component is stored again while the formula runs, the bundle is computed again from the new one, up to
+// maxBundleAttempts times; after that it is returned without storing it, the change recomputes it anyway
+func (c *TransparentCache) storeBundle(bundleCode string, bundle *bundle, componentItems []*PriceItem) *PriceItem {
+	for attempt := 1; ; attempt++ {
+		priceItem := bundlePriceItem(bundle, componentItems)
+		c.mu.Lock()
+		current, complete := c.currentComponents(bundle)
+		if complete && sameItems(current, componentItems) {
+			c.prices[bundleCode] = priceItem
+			c.mu.Unlock()
+			c.componentChanged(bundleCode)
+			return priceItem
+		}
+		c.mu.Unlock()
+		if !complete || attempt == maxBundleAttempts {
+			return priceItem
+		}
+		componentItems = current
+	}
+}
+
+// componentChanged recomputes the cached bundles that have the item as a component
+// A bundle with a component that is not cached anymore is invalidated instead, it is computed on its next lookup
+func (c *TransparentCache) componentChanged(itemCode string) {
+	for _, bundleCode := range c.cachedDependents(itemCode) {
+		c.mu.Lock()
+		bundle, ok := c.bundles[bundleCode]
+		var componentItems []*PriceItem
+		complete := false
+		if ok {
+			componentItems, complete = c.cachedComponents(bundle)
+		}
+		c.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if !complete {
+			c.Invalidate(bundleCode)
+			continue
+		}
+		c.storeBundle(bundleCode, bundle, componentItems)
+	}
+}
+
+// currentComponents returns the entries of the components of the bundle, expired or not, holding the lock
+// It is false if any of them is not in the cache
+func (c *TransparentCache) currentComponents(bundle *bundle) ([]*PriceItem, bool) {
+	componentItems := make([]*PriceItem, len(bundle.components))
+	for i, component := range bundle.components {
+		componentItem, ok := c.prices[component]
+		if !ok {
+			return nil, false
+		}
+		componentItems[i] = componentItem
+	}
+	return componentItems, true
+}
+
+// sameItems tells if both have the same entries, every store creates a new entry so a changed item is a different one
+func sameItems(a []*PriceItem, b []*PriceItem) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedComponents returns the components of the bundle that are cached and not older than maxAge, holding the lock
+// It is false if any of them is missing
+func (c *TransparentCache) cachedComponents(bundle *bundle) ([]*PriceItem, bool) {
+	componentItems, ok := c.currentComponents(bundle)
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	for _, componentItem := range componentItems {
+		if !now.Before(componentItem.dateCreated.Add(c.maxAge)) {
+			return nil, false
+		}
+	}
+	return componentItems, true
+}
+
+// bundlePriceItem computes the bundle entry from the component items
+// The bundle is as old as its oldest component, so it expires with it
+func bundlePriceItem(bundle *bundle, componentItems []*PriceItem) *PriceItem {
+	prices := make([]float64, len(componentItems))
+	dateCreated := time.Now()
+	for i, componentItem := range componentItems {
+		prices[i] = componentItem.price
+		if componentItem.dateCreated.Before(dateCreated) {
+			dateCreated = *componentItem.dateCreated
+		}
+	}
+	return &PriceItem{dateCreated: &dateCreated, price: bundle.formula(prices)}
+}
+
+// componentInvalidated invalidates the bundles that have the item as a component
+func (c *TransparentCache) componentInvalidated(itemCode string) {
+	for _, bundleCode := range c.cachedDependents(itemCode) {
+		c.Invalidate(bundleCode)
+	}
+}
+
+// cachedDependents returns the bundles that have the item as a component and are in the cache
+func (c *TransparentCache) cachedDependents(itemCode string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := []string{}
+	for _, bundleCode := range c.dependents[itemCode] {
+		if _, ok := c.prices[bundleCode]; ok {
+			cached = append(cached, bundleCode)
+		}
+	}
+	return cached
+}
+
+func without(itemCodes []string, itemCode string) []string {
+	result := []string{}
+	for _, code := range itemCodes {
+		if code != itemCode {
+			result = append(result, code)
+		}
+	}
+	return result
+}
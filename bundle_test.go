@@ -0,0 +1,236 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sumFormula(prices []float64) float64 {
+	total := 0.0
+	for _, price := range prices {
+		total += price
+	}
+	return total
+}
+
+// Check that a bundle is computed from its components and follows their refreshes and invalidations
+func TestRegisterBundle_DerivesPriceFromComponents(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	err := cache.RegisterBundle("b1", []string{"p1", "p2"}, func(prices []float64) float64 {
+		return (prices[0] + prices[1]) * 0.9
+	})
+	if err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+	assertAlmostFloat(t, 10.8, getPriceWithNoErr(t, cache, "b1"), "wrong bundle price")
+	assertAlmostFloat(t, 10.8, getPriceWithNoErr(t, cache, "b1"), "wrong bundle price")
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+
+	// refreshing a component recomputes the bundle right away
+	mockService.mockResults["p1"] = mockResult{price: 3, err: nil}
+	cache.Refresh("p1")
+	price, ok := cache.cachedPrice("b1")
+	if !ok {
+		t.Fatal("bundle was not recomputed")
+	}
+	assertAlmostFloat(t, 9, price, "wrong bundle price")
+
+	// invalidating a component invalidates the bundle, it is computed again on the next lookup
+	cache.Invalidate("p2")
+	if _, ok := cache.cachedPrice("b1"); ok {
+		t.Error("bundle was not invalidated")
+	}
+	assertAlmostFloat(t, 9, getPriceWithNoErr(t, cache, "b1"), "wrong bundle price")
+	assertInt(t, 4, mockService.getNumCalls(), "wrong number of service calls")
+
+	// bundles don't come from the actual service, so they are not refreshed from it
+	assertInt(t, 2, NewRefresher(cache, time.Minute, 10, 1).RefreshAll().Refreshed, "wrong number of refreshed items")
+}
+
+// Check that bundles of bundles are recomputed and cycles are rejected
+func TestRegisterBundle_NestedBundlesAndCycles(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	if err := cache.RegisterBundle("b1", []string{"p1", "p2"}, sumFormula); err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+	if err := cache.RegisterBundle("b2", []string{"b1", "p1"}, sumFormula); err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+	assertFloat(t, 17, getPriceWithNoErr(t, cache, "b2"), "wrong bundle price")
+
+	mockService.mockResults["p2"] = mockResult{price: 1, err: nil}
+	cache.Refresh("p2")
+	price, ok := cache.cachedPrice("b2")
+	if !ok {
+		t.Fatal("nested bundle was not recomputed")
+	}
+	assertFloat(t, 11, price, "wrong bundle price")
+
+	if err := cache.RegisterBundle("b1", []string{"b2"}, sumFormula); !errors.Is(err, ErrBundleCycle) {
+		t.Error("expected bundle cycle error, got", err)
+	}
+	if err := cache.RegisterBundle("b3", []string{"b3"}, sumFormula); !errors.Is(err, ErrBundleCycle) {
+		t.Error("expected bundle cycle error, got", err)
+	}
+	assertFloat(t, 11, getPriceWithNoErr(t, cache, "b2"), "wrong bundle price")
+}
+
+// countingPriceService returns a higher price on every call, so each refresh stores a different price
+type countingPriceService struct {
+	calls int
+	mu    *sync.Mutex
+}
+
+func (m *countingPriceService) GetPriceFor(itemCode string) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	return float64(m.calls), nil
+}
+
+// Check that concurrent refreshes of the components leave the bundle computed from the last cached prices
+func TestRegisterBundle_ConcurrentComponentRefreshes(t *testing.T) {
+	cache := NewTransparentCache(&countingPriceService{mu: &sync.Mutex{}}, time.Minute)
+	// a slow formula widens the window between reading the components and storing the bundle, it runs without the lock
+	slowSum := func(prices []float64) float64 {
+		time.Sleep(time.Millisecond)
+		return sumFormula(prices)
+	}
+	if err := cache.RegisterBundle("b1", []string{"p1", "p2"}, slowSum); err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+	getPriceWithNoErr(t, cache, "b1")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		for _, itemCode := range []string{"p1", "p2"} {
+			wg.Add(1)
+			go func(itemCode string) {
+				defer wg.Done()
+				cache.Refresh(itemCode)
+			}(itemCode)
+		}
+	}
+	wg.Wait()
+
+	p1, _ := cache.cachedPrice("p1")
+	p2, _ := cache.cachedPrice("p2")
+	price, ok := cache.cachedPrice("b1")
+	if !ok {
+		t.Fatal("bundle was not recomputed")
+	}
+	assertFloat(t, p1+p2, price, "bundle computed from stale components")
+}
+
+// Check that registering a bundle over a wide shared graph doesn't walk the shared components again
+func TestRegisterBundle_CycleCheckVisitsSharedComponentsOnce(t *testing.T) {
+	cache := NewTransparentCache(&countingPriceService{mu: &sync.Mutex{}}, time.Minute)
+	// every level has two bundles over both bundles of the level below, without memoization it is 2^40 walks
+	previous := []string{"p1", "p2"}
+	for level := 0; level < 40; level++ {
+		current := []string{}
+		for _, side := range []string{"a", "b"} {
+			bundleCode := fmt.Sprintf("%v%v", side, level)
+			if err := cache.RegisterBundle(bundleCode, previous, sumFormula); err != nil {
+				t.Fatal("error registering bundle", err)
+			}
+			current = append(current, bundleCode)
+		}
+		previous = current
+	}
+	if err := cache.RegisterBundle("p1", previous, sumFormula); !errors.Is(err, ErrBundleCycle) {
+		t.Error("expected bundle cycle error, got", err)
+	}
+}
+
+// Check that a formula can read the cache, it runs without holding the cache lock
+func TestRegisterBundle_FormulaCanReadTheCache(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1":       {price: 5, err: nil},
+			"discount": {price: 2, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	err := cache.RegisterBundle("b1", []string{"p1"}, func(prices []float64) float64 {
+		discount, _ := cache.GetPriceFor("discount")
+		return prices[0] - discount
+	})
+	if err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+
+	done := make(chan float64)
+	go func() {
+		done <- getPriceWithNoErr(t, cache, "b1")
+	}()
+	select {
+	case price := <-done:
+		assertFloat(t, 3, price, "wrong bundle price")
+	case <-time.After(time.Second):
+		t.Fatal("bundle lookup deadlocked")
+	}
+}
+
+// Check that the component fills of a bundle count against the quota of the caller asking for it
+func TestRegisterBundle_ComponentFillsAreChargedToTheCaller(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	scheduler := NewFairScheduler(0, CallerQuota{})
+	cache := NewTransparentCache(mockService, time.Minute, WithFairScheduler(scheduler))
+	if err := cache.RegisterBundle("b1", []string{"p1", "p2"}, sumFormula); err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+	if _, err := cache.GetPriceForCaller("c1", "b1"); err != nil {
+		t.Fatal("error getting bundle price", err)
+	}
+	stats := scheduler.Stats()
+	assertInt(t, 2, stats["c1"].Fills, "wrong number of fills for the caller")
+	assertInt(t, 0, stats[DefaultCaller].Fills, "wrong number of fills for the default caller")
+}
+
+// Check that a price change for a bundle code doesn't overwrite the derived price
+func TestRegisterBundle_IgnoresDirectPriceChanges(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	if err := cache.RegisterBundle("b1", []string{"p1", "p2"}, sumFormula); err != nil {
+		t.Fatal("error registering bundle", err)
+	}
+	getPriceWithNoErr(t, cache, "b1")
+
+	changes := &mockChangesService{
+		changes: []PriceChange{{ItemCode: "b1", Price: 100}},
+		cursors: []time.Time{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	result, err := NewSyncer(cache, changes, NewFileCursorStore(filepath.Join(t.TempDir(), "cursor")), time.Minute).Sync()
+	if err != nil {
+		t.Fatal("error syncing", err)
+	}
+	assertInt(t, 0, result.Updated, "wrong number of updated items")
+	assertFloat(t, 12, getPriceWithNoErr(t, cache, "b1"), "wrong bundle price")
+}
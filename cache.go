@@ -20,53 +20,326 @@ type TransparentCache struct {
 	actualPriceService PriceService
 	maxAge             time.Duration
 	prices             map[string]*PriceItem
+	scheduler          *FairScheduler
+	softTTL            time.Duration
+	refreshMode        RefreshMode
+	refreshing         map[string]bool
+	ttlStats           *TTLStats
+	sloTracker         *SLOTracker
+	attributes         *attributeIndex
+	capacitySampler    *CapacitySampler
+	hierarchy          ItemHierarchy
+	inheritors         map[string][]string // inherited items by the ancestor their price came from
+	bundles            map[string]*bundle
+	dependents         map[string][]string // bundles by component
+	itemKind           string              // what the cached values are, used in the errors
 	mu                 *sync.Mutex
 }
 
+// Option configures optional behaviors of the TransparentCache
+type Option func(*TransparentCache)
+
+// WithFairScheduler makes the upstream fills go through the scheduler, applying per-caller quotas
+func WithFairScheduler(scheduler *FairScheduler) Option {
+	return func(c *TransparentCache) {
+		c.scheduler = scheduler
+	}
+}
+
+// withItemKind names what the cache holds in its errors, for the caches that store other values than prices
+func withItemKind(itemKind string) Option {
+	return func(c *TransparentCache) {
+		c.itemKind = itemKind
+	}
+}
+
 // PriceItem is the item stored in the cache with its creation date and its corresponding price.
 type PriceItem struct {
 	dateCreated *time.Time
 	price       float64
+	unit        Unit   // the unit the price is for, empty if the actual service doesn't return units
+	source      string // the ancestor the price was inherited from, empty if it is the price of the item
 }
 
-func NewTransparentCache(actualPriceService PriceService, maxAge time.Duration) *TransparentCache {
-	return &TransparentCache{
+func NewTransparentCache(actualPriceService PriceService, maxAge time.Duration, opts ...Option) *TransparentCache {
+	cache := &TransparentCache{
 		actualPriceService: actualPriceService,
 		maxAge:             maxAge,
 		prices:             map[string]*PriceItem{},
+		refreshing:         map[string]bool{},
+		ttlStats:           &TTLStats{},
+		attributes:         newAttributeIndex(),
+		inheritors:         map[string][]string{},
+		bundles:            map[string]*bundle{},
+		dependents:         map[string][]string{},
+		itemKind:           "price",
 		mu:                 &sync.Mutex{},
 	}
+	for _, opt := range opts {
+		opt(cache)
+	}
+	return cache
 }
 
 // GetPriceFor gets the price for the item, either from the cache or the actual service if it was not cached or too old
 func (c *TransparentCache) GetPriceFor(itemCode string) (float64, error) {
+	return c.GetPriceForCaller(DefaultCaller, itemCode)
+}
+
+// GetPriceForCaller is GetPriceFor on behalf of caller, the upstream fill counts against the caller quota
+func (c *TransparentCache) GetPriceForCaller(caller string, itemCode string) (float64, error) {
+	priceItem, err := c.getPriceItem(caller, itemCode)
+	if err != nil {
+		return 0, err
+	}
+	return priceItem.price, nil
+}
+
+// getPriceItem gets the cached item, filling it from the actual service if it was not cached or too old
+func (c *TransparentCache) getPriceItem(caller string, itemCode string) (*PriceItem, error) {
+	return c.lookup(caller, itemCode, nil)
+}
+
+// lookup is getPriceItem recording its decisions in trace, which can be nil
+func (c *TransparentCache) lookup(caller string, itemCode string, trace *LookupTrace) (*PriceItem, error) {
+	if c.capacitySampler != nil {
+		c.capacitySampler.Record(itemCode, time.Now())
+	}
+	if c.sloTracker == nil {
+		return c.lookupEntry(caller, itemCode, trace)
+	}
+	start := time.Now()
+	priceItem, err := c.lookupEntry(caller, itemCode, trace)
+	var age time.Duration
+	if err == nil {
+		age = time.Since(*priceItem.dateCreated)
+	}
+	c.sloTracker.Record(age, time.Since(start), err)
+	return priceItem, err
+}
+
+func (c *TransparentCache) lookupEntry(caller string, itemCode string, trace *LookupTrace) (*PriceItem, error) {
+	c.mu.Lock()
 	priceItem, ok := c.prices[itemCode]
+	c.ttlStats.Lookups++
+	c.mu.Unlock()
+	trace.found(priceItem)
 	if ok {
 		if time.Now().Before(priceItem.dateCreated.Add(c.maxAge)) {
-			return priceItem.price, nil
+			if c.isSoftExpired(priceItem) {
+				return c.serveSoftExpired(caller, itemCode, priceItem, trace), nil
+			}
+			trace.decide(FreshHit, "age is below the soft TTL and maxAge, served from the cache")
+			c.countTTL(func(stats *TTLStats) { stats.FreshHits++ })
+			return priceItem, nil
 		}
+		trace.decide(HardExpired, "age is over maxAge, the entry can't be served")
+		c.countTTL(func(stats *TTLStats) { stats.HardExpirations++ })
+	} else {
+		trace.decide(Miss, "item is not in the cache")
+		c.countTTL(func(stats *TTLStats) { stats.Misses++ })
 	}
-	price, err := c.actualPriceService.GetPriceFor(itemCode)
+	priceItem, err := c.fillItem(caller, itemCode, trace)
 	if err != nil {
-		return 0, fmt.Errorf("getting price from service : %v", err.Error())
+		return nil, fmt.Errorf("getting %v from service : %w", c.itemKind, err)
+	}
+	return priceItem, nil
+}
+
+// fillItem fills the item from the actual service and stores it
+// If the service has no price for it and the cache has a hierarchy, the price is inherited from the closest ancestor
+func (c *TransparentCache) fillItem(caller string, itemCode string, trace *LookupTrace) (*PriceItem, error) {
+	if bundle, ok := c.bundle(itemCode); ok {
+		return c.computeBundle(caller, itemCode, bundle, trace)
+	}
+	price, unit, err := c.tracedFill(caller, itemCode, trace)
+	if err == nil {
+		return c.store(itemCode, price, unit), nil
+	}
+	if c.hierarchy == nil || !errors.Is(err, ErrPriceNotFound) {
+		return nil, err
+	}
+	return c.inherit(caller, itemCode, err, trace)
+}
+
+// getPriceItems gets the items in parallel on behalf of caller, keeping the order of the item codes and the error of each one
+func (c *TransparentCache) getPriceItems(caller string, itemCodes []string) ([]*PriceItem, []error) {
+	priceItems := make([]*PriceItem, len(itemCodes))
+	errs := make([]error, len(itemCodes))
+	wg := &sync.WaitGroup{}
+	for i, itemCode := range itemCodes {
+		wg.Add(1)
+		go func(i int, itemCode string) {
+			defer wg.Done()
+			priceItems[i], errs[i] = c.getPriceItem(caller, itemCode)
+		}(i, itemCode)
 	}
+	wg.Wait()
+	return priceItems, errs
+}
+
+// Refresh gets the price for the item from the actual service and caches it, even if the cached one is still valid
+func (c *TransparentCache) Refresh(itemCode string) (float64, error) {
+	priceItem, err := c.fillItem(DefaultCaller, itemCode, nil)
+	if err != nil {
+		return 0, fmt.Errorf("getting %v from service : %w", c.itemKind, err)
+	}
+	return priceItem.price, nil
+}
+
+// store saves the price for the item as created now, updating the bundles and inherited items that depend on it
+func (c *TransparentCache) store(itemCode string, price float64, unit Unit) *PriceItem {
 	dateCreated := time.Now()
-	priceItem = &PriceItem{dateCreated: &dateCreated, price: price}
+	priceItem := &PriceItem{dateCreated: &dateCreated, price: price, unit: unit}
 	c.mu.Lock()
 	c.prices[itemCode] = priceItem
 	c.mu.Unlock()
-	return price, nil
+	c.ancestorChanged(itemCode)
+	c.componentChanged(itemCode)
+	return priceItem
+}
+
+// update saves the price for the item only if it is already in the cache, it returns if it was updated
+// A price without unit for an entry that has one can't be paired with the old unit, so the entry is invalidated
+// instead and it returns invalidated, the next lookup gets the price and its unit again
+// Bundles are never updated, their price is derived from their components
+func (c *TransparentCache) update(itemCode string, price float64, unit Unit) (updated bool, invalidated bool) {
+	dateCreated := time.Now()
+	c.mu.Lock()
+	old, ok := c.prices[itemCode]
+	if _, isBundle := c.bundles[itemCode]; !ok || isBundle {
+		c.mu.Unlock()
+		return false, false
+	}
+	if unit == "" && old.unit != "" {
+		c.mu.Unlock()
+		return false, c.Invalidate(itemCode)
+	}
+	c.prices[itemCode] = &PriceItem{dateCreated: &dateCreated, price: price, unit: unit}
+	c.mu.Unlock()
+	c.ancestorChanged(itemCode)
+	c.componentChanged(itemCode)
+	return true, false
+}
+
+// Invalidate removes the item from the cache, so the next lookup gets it from the actual service
+// It returns if the item was in the cache
+func (c *TransparentCache) Invalidate(itemCode string) bool {
+	c.mu.Lock()
+	_, ok := c.prices[itemCode]
+	delete(c.prices, itemCode)
+	c.mu.Unlock()
+	c.ancestorChanged(itemCode)
+	c.componentInvalidated(itemCode)
+	return ok
+}
+
+// cachedPrice returns the price of the item if it is in the cache and not older than maxAge
+func (c *TransparentCache) cachedPrice(itemCode string) (float64, bool) {
+	priceItem, ok := c.cachedItem(itemCode)
+	if !ok {
+		return 0, false
+	}
+	return priceItem.price, true
+}
+
+// cachedItem returns the item if it is in the cache and not older than maxAge
+func (c *TransparentCache) cachedItem(itemCode string) (*PriceItem, bool) {
+	c.mu.Lock()
+	priceItem, ok := c.prices[itemCode]
+	c.mu.Unlock()
+	if !ok || !time.Now().Before(priceItem.dateCreated.Add(c.maxAge)) {
+		return nil, false
+	}
+	return priceItem, true
+}
+
+// remainingTTL returns how long the cached item will still be served, it is false if it is not cached or too old
+func (c *TransparentCache) remainingTTL(itemCode string) (time.Duration, bool) {
+	c.mu.Lock()
+	priceItem, ok := c.prices[itemCode]
+	c.mu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	ttl := time.Until(priceItem.dateCreated.Add(c.maxAge))
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// itemCodes returns the codes of all the items from the actual service in the cache, expired or not
+// Bundles and inherited items are left out, the actual service has no price for them
+func (c *TransparentCache) itemCodes() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	itemCodes := make([]string, 0, len(c.prices))
+	for itemCode, priceItem := range c.prices {
+		if _, ok := c.bundles[itemCode]; !ok && priceItem.source == "" {
+			itemCodes = append(itemCodes, itemCode)
+		}
+	}
+	return itemCodes
+}
+
+// fill gets the price from the actual service, through the scheduler if there is one
+func (c *TransparentCache) fill(caller string, itemCode string) (float64, Unit, error) {
+	if c.scheduler == nil {
+		return c.fetch(itemCode)
+	}
+	var unit Unit
+	price, err := c.scheduler.Do(caller, func() (float64, error) {
+		price, fetchedUnit, err := c.fetch(itemCode)
+		unit = fetchedUnit
+		return price, err
+	})
+	return price, unit, err
+}
+
+// fetch gets the price from the actual service, with its unit if the service returns units
+// It also indexes the attributes if the service returns them
+func (c *TransparentCache) fetch(itemCode string) (float64, Unit, error) {
+	attributedService, ok := c.actualPriceService.(AttributedPriceService)
+	if !ok {
+		return c.fetchWithUnit(itemCode)
+	}
+	price, attributes, err := attributedService.GetPriceWithAttributesFor(itemCode)
+	if err != nil {
+		return 0, "", err
+	}
+	c.attributes.set(itemCode, attributes)
+	if _, ok := c.actualPriceService.(UnitPricedService); !ok {
+		return price, "", nil
+	}
+	// there is no call returning the attributes and the unit, the price is taken from the call with the unit
+	// so the price and its unit always come together
+	return c.fetchWithUnit(itemCode)
+}
+
+// fetchWithUnit gets the price from the actual service, with its unit if the service returns units
+func (c *TransparentCache) fetchWithUnit(itemCode string) (float64, Unit, error) {
+	if unitService, ok := c.actualPriceService.(UnitPricedService); ok {
+		return unitService.GetPriceWithUnitFor(itemCode)
+	}
+	price, err := c.actualPriceService.GetPriceFor(itemCode)
+	return price, "", err
 }
 
 // GetPricesFor gets the prices for several items at once, some might be found in the cache, others might not
 // If any of the operations returns an error, it should return an error as well
 func (c *TransparentCache) GetPricesFor(itemCodes ...string) ([]float64, error) {
+	return c.GetPricesForCaller(DefaultCaller, itemCodes...)
+}
+
+// GetPricesForCaller is GetPricesFor on behalf of caller
+func (c *TransparentCache) GetPricesForCaller(caller string, itemCodes ...string) ([]float64, error) {
 	results := []float64{}
 	priceChan := make(chan float64, len(itemCodes))
 	errChan := make(chan error)
 	for _, itemCode := range itemCodes {
 		go func(itemCode string) {
-			price, err := c.GetPriceFor(itemCode)
+			price, err := c.GetPriceForCaller(caller, itemCode)
 			if err != nil {
 				errChan <- err
 			}
@@ -3,6 +3,7 @@ package sample1
 import (
 	"fmt"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -14,15 +15,15 @@ type mockResult struct {
 }
 
 type mockPriceService struct {
-	numCalls    int
+	numCalls    int32
 	mockResults map[string]mockResult // what price and err to return for a particular itemCode
 	callDelay   time.Duration         // how long to sleep on each call so that we can simulate calls to be expensive
 }
 
 func (m *mockPriceService) GetPriceFor(itemCode string) (float64, error) {
 
-	m.numCalls++            // increase the number of calls
-	time.Sleep(m.callDelay) // sleep to simulate expensive call
+	atomic.AddInt32(&m.numCalls, 1) // increase the number of calls, atomically because lookups run in parallel
+	time.Sleep(m.callDelay)         // sleep to simulate expensive call
 
 	result, ok := m.mockResults[itemCode]
 	if !ok {
@@ -32,7 +33,7 @@ func (m *mockPriceService) GetPriceFor(itemCode string) (float64, error) {
 }
 
 func (m *mockPriceService) getNumCalls() int {
-	return m.numCalls
+	return int(atomic.LoadInt32(&m.numCalls))
 }
 
 func getPriceWithNoErr(t *testing.T, cache *TransparentCache, itemCode string) float64 {
@@ -0,0 +1,164 @@
+package sample1
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxWorstOffenders is the amount of divergences kept in the consistency report
+const maxWorstOffenders = 10
+
+// Divergence is an item for which the cache served a different price than the actual service
+type Divergence struct {
+	ItemCode      string
+	CachedPrice   float64
+	UpstreamPrice float64
+	CheckedAt     time.Time
+}
+
+// Diff is the absolute difference between the cached and the upstream price
+func (d Divergence) Diff() float64 {
+	return math.Abs(d.CachedPrice - d.UpstreamPrice)
+}
+
+// ConsistencyReport is the result of the checks done so far
+type ConsistencyReport struct {
+	Checked        int
+	Mismatches     int
+	Errors         int
+	Invalidated    int
+	WorstOffenders []Divergence // biggest differences first
+}
+
+// DivergenceRate is the fraction of the checked items that didn't match the upstream
+func (r ConsistencyReport) DivergenceRate() float64 {
+	if r.Checked == 0 {
+		return 0
+	}
+	return float64(r.Mismatches) / float64(r.Checked)
+}
+
+// ConsistencyChecker periodically compares a sample of the cached items against the actual service
+// The upstream calls are done out of band, the cached entries are not refreshed by them
+type ConsistencyChecker struct {
+	cache      *TransparentCache
+	sampleSize int
+	interval   time.Duration
+	invalidate bool
+	report     *ConsistencyReport
+	mu         *sync.Mutex
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewConsistencyChecker creates a checker that compares sampleSize cached items every interval
+// If invalidate is true the mismatched items are removed from the cache
+func NewConsistencyChecker(cache *TransparentCache, sampleSize int, interval time.Duration, invalidate bool) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		cache:      cache,
+		sampleSize: sampleSize,
+		interval:   interval,
+		invalidate: invalidate,
+		report:     &ConsistencyReport{},
+		mu:         &sync.Mutex{},
+	}
+}
+
+// Start runs the check in the background every interval until Stop is called
+func (c *ConsistencyChecker) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Check()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background check and waits for the current one to finish
+func (c *ConsistencyChecker) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+// Report returns a copy of the results of all the checks done so far
+func (c *ConsistencyChecker) Report() ConsistencyReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := *c.report
+	report.WorstOffenders = append([]Divergence{}, c.report.WorstOffenders...)
+	return report
+}
+
+// Check compares a random sample of the cached items against the actual service once
+func (c *ConsistencyChecker) Check() {
+	itemCodes := c.cache.itemCodes()
+	rand.Shuffle(len(itemCodes), func(i, j int) {
+		itemCodes[i], itemCodes[j] = itemCodes[j], itemCodes[i]
+	})
+	if len(itemCodes) > c.sampleSize {
+		itemCodes = itemCodes[:c.sampleSize]
+	}
+	for _, itemCode := range itemCodes {
+		c.checkItem(itemCode)
+	}
+}
+
+func (c *ConsistencyChecker) checkItem(itemCode string) {
+	cachedPrice, ok := c.cache.cachedPrice(itemCode)
+	if !ok {
+		// expired entries are not served, so they can't diverge
+		return
+	}
+	upstreamPrice, err := c.cache.actualPriceService.GetPriceFor(itemCode)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.report.Errors++
+		return
+	}
+	c.report.Checked++
+	if cachedPrice == upstreamPrice {
+		return
+	}
+	c.report.Mismatches++
+	c.addOffender(Divergence{
+		ItemCode:      itemCode,
+		CachedPrice:   cachedPrice,
+		UpstreamPrice: upstreamPrice,
+		CheckedAt:     time.Now(),
+	})
+	if c.invalidate {
+		c.cache.Invalidate(itemCode)
+		c.report.Invalidated++
+	}
+}
+
+// addOffender keeps the divergence if it is one of the biggest, replacing older ones for the same item
+func (c *ConsistencyChecker) addOffender(divergence Divergence) {
+	offenders := []Divergence{divergence}
+	for _, offender := range c.report.WorstOffenders {
+		if offender.ItemCode != divergence.ItemCode {
+			offenders = append(offenders, offender)
+		}
+	}
+	sort.SliceStable(offenders, func(i, j int) bool {
+		return offenders[i].Diff() > offenders[j].Diff()
+	})
+	if len(offenders) > maxWorstOffenders {
+		offenders = offenders[:maxWorstOffenders]
+	}
+	c.report.WorstOffenders = offenders
+}
@@ -0,0 +1,54 @@
+package sample1
+
+import (
+	"testing"
+	"time"
+)
+
+// Check that the checker reports divergences and invalidates the mismatched items
+func TestConsistencyChecker_ReportsAndInvalidatesMismatches(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+			"p3": {price: 9, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPricesWithNoErr(t, cache, "p1", "p2", "p3")
+	mockService.mockResults["p2"] = mockResult{price: 8, err: nil}
+	mockService.mockResults["p3"] = mockResult{price: 1, err: nil}
+
+	checker := NewConsistencyChecker(cache, 10, time.Minute, true)
+	checker.Check()
+	report := checker.Report()
+	assertInt(t, 3, report.Checked, "wrong number of checked items")
+	assertInt(t, 2, report.Mismatches, "wrong number of mismatches")
+	assertInt(t, 2, report.Invalidated, "wrong number of invalidated items")
+	assertFloat(t, 2.0/3.0, report.DivergenceRate(), "wrong divergence rate")
+	if len(report.WorstOffenders) != 2 || report.WorstOffenders[0].ItemCode != "p3" {
+		t.Error("wrong worst offenders", report.WorstOffenders)
+	}
+
+	// the invalidated items are fetched again, the matching one is still cached
+	calls := mockService.getNumCalls()
+	assertFloats(t, []float64{5, 8, 1}, getPricesWithNoErr(t, cache, "p1", "p2", "p3"), "wrong price returned")
+	assertInt(t, calls+2, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that the checker only looks at a sample of the cache
+func TestConsistencyChecker_ChecksSample(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+			"p3": {price: 9, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPricesWithNoErr(t, cache, "p1", "p2", "p3")
+	checker := NewConsistencyChecker(cache, 2, time.Minute, false)
+	checker.Check()
+	assertInt(t, 2, checker.Report().Checked, "wrong number of checked items")
+	assertInt(t, 0, checker.Report().Mismatches, "wrong number of mismatches")
+}
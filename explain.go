@@ -0,0 +1,91 @@
+package sample1
+
+import (
+	"fmt"
+	"time"
+)
+
+// LookupDecision is what the cache decided to do with the entry it found for the item
+type LookupDecision string
+
+const (
+	FreshHit              LookupDecision = "fresh hit"
+	SoftExpiredInline     LookupDecision = "soft expired, inline refresh"
+	SoftExpiredBackground LookupDecision = "soft expired, background refresh"
+	HardExpired           LookupDecision = "hard expired"
+	Miss                  LookupDecision = "miss"
+)
+
+// LookupTrace explains why a lookup did what it did
+// Upstream fields are only set if the actual service was called during the lookup
+type LookupTrace struct {
+	ItemCode         string
+	Caller           string
+	Found            bool
+	Age              time.Duration
+	MaxAge           time.Duration
+	SoftTTL          time.Duration // 0 if the cache has no soft TTL
+	Decision         LookupDecision
+	Rules            []string // the rules applied, in order
+	UpstreamCalled   bool
+	Scheduled        bool // the upstream call went through the FairScheduler
+	UpstreamDuration time.Duration
+	UpstreamError    error
+}
+
+// ExplainPriceFor gets the price like GetPriceForCaller and also returns the trace of the lookup
+func (c *TransparentCache) ExplainPriceFor(caller string, itemCode string) (float64, *LookupTrace, error) {
+	trace := &LookupTrace{
+		ItemCode: itemCode,
+		Caller:   caller,
+		MaxAge:   c.maxAge,
+		SoftTTL:  c.softTTL,
+	}
+	priceItem, err := c.lookup(caller, itemCode, trace)
+	if err != nil {
+		return 0, trace, err
+	}
+	return priceItem.price, trace, nil
+}
+
+// tracedFill is fill recording the upstream call in trace
+func (c *TransparentCache) tracedFill(caller string, itemCode string, trace *LookupTrace) (float64, Unit, error) {
+	if trace == nil {
+		return c.fill(caller, itemCode)
+	}
+	start := time.Now()
+	price, unit, err := c.fill(caller, itemCode)
+	trace.UpstreamCalled = true
+	trace.Scheduled = c.scheduler != nil
+	trace.UpstreamDuration = time.Since(start)
+	trace.UpstreamError = err
+	if trace.Scheduled {
+		trace.rule(fmt.Sprintf("upstream call scheduled for caller %q", caller))
+	}
+	return price, unit, err
+}
+
+// The trace methods do nothing on a nil trace, so lookups that are not explained don't pay for them
+
+func (t *LookupTrace) found(priceItem *PriceItem) {
+	if t == nil || priceItem == nil {
+		return
+	}
+	t.Found = true
+	t.Age = time.Since(*priceItem.dateCreated)
+}
+
+func (t *LookupTrace) decide(decision LookupDecision, rule string) {
+	if t == nil {
+		return
+	}
+	t.Decision = decision
+	t.rule(rule)
+}
+
+func (t *LookupTrace) rule(rule string) {
+	if t == nil {
+		return
+	}
+	t.Rules = append(t.Rules, rule)
+}
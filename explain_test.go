@@ -0,0 +1,57 @@
+package sample1
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Check that the trace explains a miss followed by a fresh hit
+func TestExplainPriceFor_MissThenHit(t *testing.T) {
+	mockService := &mockPriceService{
+		callDelay: 10 * time.Millisecond,
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithFairScheduler(NewFairScheduler(0, CallerQuota{})))
+	price, trace, err := cache.ExplainPriceFor("c1", "p1")
+	if err != nil {
+		t.Fatal("error getting price for p1", err)
+	}
+	assertFloat(t, 5, price, "wrong price returned")
+	if trace.Found || trace.Decision != Miss || !trace.UpstreamCalled || !trace.Scheduled {
+		t.Errorf("wrong trace for a miss : %+v", trace)
+	}
+	if trace.UpstreamDuration < 10*time.Millisecond {
+		t.Error("upstream duration not recorded", trace.UpstreamDuration)
+	}
+
+	_, trace, _ = cache.ExplainPriceFor("c1", "p1")
+	if !trace.Found || trace.Decision != FreshHit || trace.UpstreamCalled || trace.Age >= trace.MaxAge {
+		t.Errorf("wrong trace for a hit : %+v", trace)
+	}
+	assertInt(t, 1, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that the trace explains a failed inline refresh of a soft expired entry
+func TestExplainPriceFor_SoftExpiredRefreshError(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithSoftTTL(10*time.Millisecond, InlineRefresh))
+	getPriceWithNoErr(t, cache, "p1")
+	time.Sleep(20 * time.Millisecond)
+	mockService.mockResults["p1"] = mockResult{price: 0, err: fmt.Errorf("some error")}
+
+	price, trace, err := cache.ExplainPriceFor(DefaultCaller, "p1")
+	if err != nil {
+		t.Fatal("error getting price for p1", err)
+	}
+	assertFloat(t, 5, price, "wrong price returned")
+	if trace.Decision != SoftExpiredInline || trace.UpstreamError == nil || len(trace.Rules) != 2 {
+		t.Errorf("wrong trace for a soft expired entry : %+v", trace)
+	}
+}
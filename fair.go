@@ -0,0 +1,221 @@
+package sample1
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCaller is the caller identity used by lookups that don't pass one
+const DefaultCaller = ""
+
+// CallerQuota limits how much of the upstream capacity a single caller can use
+// Rate is the amount of upstream fills per second (0 means unlimited) and Burst how many of them can be done at once
+// MaxConcurrent is the amount of upstream fills in flight for the caller (0 means unlimited)
+// Weight is the share of the upstream capacity the caller gets when several callers are waiting
+type CallerQuota struct {
+	Rate          float64
+	Burst         int
+	MaxConcurrent int
+	Weight        int
+}
+
+// CallerStats are the statistics of the upstream fills done for a caller
+type CallerStats struct {
+	Fills       int
+	RateLimited int
+	Queued      int
+	InFlight    int
+	WaitTime    time.Duration
+}
+
+// FairScheduler shares the upstream capacity between callers
+// Each caller has its own quota and waiting fills are dispatched with weighted fair queuing
+type FairScheduler struct {
+	capacity     int
+	defaultQuota CallerQuota
+	callers      map[string]*callerState
+	waiting      []*fillRequest
+	inFlight     int
+	virtualTime  float64
+	mu           *sync.Mutex
+}
+
+type callerState struct {
+	quota      CallerQuota
+	tokens     float64
+	lastRefill *time.Time
+	lastTag    float64
+	stats      *CallerStats
+}
+
+// fillRequest is a fill waiting for an upstream slot, ready is closed when it can run
+type fillRequest struct {
+	caller string
+	tag    float64
+	ready  chan struct{}
+}
+
+// NewFairScheduler creates a scheduler allowing capacity upstream fills at the same time (0 means unlimited)
+// Callers without an explicit quota use defaultQuota
+func NewFairScheduler(capacity int, defaultQuota CallerQuota) *FairScheduler {
+	return &FairScheduler{
+		capacity:     capacity,
+		defaultQuota: defaultQuota,
+		callers:      map[string]*callerState{},
+		mu:           &sync.Mutex{},
+	}
+}
+
+// SetQuota sets the quota for a caller
+func (s *FairScheduler) SetQuota(caller string, quota CallerQuota) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.callerState(caller)
+	state.quota = quota
+	state.tokens = float64(burst(quota))
+}
+
+// Stats returns the statistics of every caller that has done upstream fills
+func (s *FairScheduler) Stats() map[string]CallerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]CallerStats, len(s.callers))
+	for caller, state := range s.callers {
+		stats[caller] = *state.stats
+	}
+	return stats
+}
+
+// Do runs fill for the caller once its quota and the fair share of the upstream capacity allow it
+func (s *FairScheduler) Do(caller string, fill func() (float64, error)) (float64, error) {
+	start := time.Now()
+	s.waitForRate(caller)
+	s.acquire(caller)
+	s.mu.Lock()
+	s.callers[caller].stats.WaitTime += time.Since(start)
+	s.mu.Unlock()
+	defer s.release(caller)
+	return fill()
+}
+
+// waitForRate takes a token from the caller bucket, sleeping until there is one available
+func (s *FairScheduler) waitForRate(caller string) {
+	s.mu.Lock()
+	state := s.callerState(caller)
+	if state.quota.Rate <= 0 {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	elapsed := now.Sub(*state.lastRefill).Seconds()
+	state.lastRefill = &now
+	state.tokens += elapsed * state.quota.Rate
+	if limit := float64(burst(state.quota)); state.tokens > limit {
+		state.tokens = limit
+	}
+	state.tokens--
+	var wait time.Duration
+	if state.tokens < 0 {
+		wait = time.Duration(-state.tokens / state.quota.Rate * float64(time.Second))
+		state.stats.RateLimited++
+	}
+	s.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// acquire blocks until the caller gets an upstream slot
+func (s *FairScheduler) acquire(caller string) {
+	s.mu.Lock()
+	state := s.callerState(caller)
+	tag := s.virtualTime
+	if state.lastTag > tag {
+		tag = state.lastTag
+	}
+	tag += 1 / float64(weight(state.quota))
+	state.lastTag = tag
+	if len(s.waiting) == 0 && s.canRun(state) {
+		s.start(state, tag)
+		s.mu.Unlock()
+		return
+	}
+	request := &fillRequest{caller: caller, tag: tag, ready: make(chan struct{})}
+	s.waiting = append(s.waiting, request)
+	state.stats.Queued++
+	s.mu.Unlock()
+	<-request.ready
+}
+
+// release frees the caller slot and dispatches the waiting fills that can run now
+func (s *FairScheduler) release(caller string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	s.callers[caller].stats.InFlight--
+	s.dispatch()
+}
+
+// dispatch starts waiting fills by lowest tag, skipping callers at their concurrency limit
+func (s *FairScheduler) dispatch() {
+	for {
+		next := -1
+		for i, request := range s.waiting {
+			if !s.canRun(s.callers[request.caller]) {
+				continue
+			}
+			if next == -1 || request.tag < s.waiting[next].tag {
+				next = i
+			}
+		}
+		if next == -1 {
+			return
+		}
+		request := s.waiting[next]
+		s.waiting = append(s.waiting[:next], s.waiting[next+1:]...)
+		s.start(s.callers[request.caller], request.tag)
+		close(request.ready)
+	}
+}
+
+func (s *FairScheduler) canRun(state *callerState) bool {
+	if s.capacity > 0 && s.inFlight >= s.capacity {
+		return false
+	}
+	return state.quota.MaxConcurrent <= 0 || state.stats.InFlight < state.quota.MaxConcurrent
+}
+
+func (s *FairScheduler) start(state *callerState, tag float64) {
+	s.inFlight++
+	s.virtualTime = tag
+	state.stats.InFlight++
+	state.stats.Fills++
+}
+
+// callerState returns the state of the caller, creating it with the default quota if it is new
+func (s *FairScheduler) callerState(caller string) *callerState {
+	state, ok := s.callers[caller]
+	if !ok {
+		now := time.Now()
+		state = &callerState{
+			quota:      s.defaultQuota,
+			tokens:     float64(burst(s.defaultQuota)),
+			lastRefill: &now,
+			stats:      &CallerStats{},
+		}
+		s.callers[caller] = state
+	}
+	return state
+}
+
+func burst(quota CallerQuota) int {
+	if quota.Burst <= 0 {
+		return 1
+	}
+	return quota.Burst
+}
+
+func weight(quota CallerQuota) int {
+	if quota.Weight <= 0 {
+		return 1
+	}
+	return quota.Weight
+}
@@ -0,0 +1,127 @@
+package sample1
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForStats waits until the stats of the caller satisfy cond
+func waitForStats(t *testing.T, scheduler *FairScheduler, caller string, cond func(CallerStats) bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond(scheduler.Stats()[caller]) {
+		if time.Now().After(deadline) {
+			t.Fatal("fills were not scheduled as expected for", caller)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func queued(n int) func(CallerStats) bool {
+	return func(stats CallerStats) bool { return stats.Queued >= n }
+}
+
+// Check that cache hits don't count as upstream fills for the caller
+func TestGetPriceForCaller_OnlyCountsUpstreamFills(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	scheduler := NewFairScheduler(0, CallerQuota{})
+	cache := NewTransparentCache(mockService, time.Minute, WithFairScheduler(scheduler))
+	for i := 0; i < 3; i++ {
+		price, err := cache.GetPriceForCaller("c1", "p1")
+		if err != nil {
+			t.Fatal("error getting price for p1")
+		}
+		assertFloat(t, 5, price, "wrong price returned")
+	}
+	assertInt(t, 1, scheduler.Stats()["c1"].Fills, "wrong number of fills")
+	assertInt(t, 1, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that a caller can't have more fills in flight than its quota allows
+func TestGetPricesForCaller_LimitsConcurrency(t *testing.T) {
+	mockService := &mockPriceService{
+		callDelay: 100 * time.Millisecond,
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	scheduler := NewFairScheduler(0, CallerQuota{MaxConcurrent: 1})
+	cache := NewTransparentCache(mockService, time.Minute, WithFairScheduler(scheduler))
+	start := time.Now()
+	prices, err := cache.GetPricesForCaller("c1", "p1", "p2")
+	if err != nil {
+		t.Fatal("error getting prices")
+	}
+	assertFloats(t, []float64{5, 7}, prices, "wrong price returned")
+	if time.Since(start) < 200*time.Millisecond {
+		t.Error("calls were not serialized")
+	}
+	stats := scheduler.Stats()["c1"]
+	assertInt(t, 2, stats.Fills, "wrong number of fills")
+	assertInt(t, 1, stats.Queued, "wrong number of queued fills")
+	assertInt(t, 0, stats.InFlight, "wrong number of fills in flight")
+}
+
+// Check that a caller can't do fills faster than its rate
+func TestFairScheduler_LimitsRate(t *testing.T) {
+	scheduler := NewFairScheduler(0, CallerQuota{})
+	scheduler.SetQuota("c1", CallerQuota{Rate: 20, Burst: 1})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		scheduler.Do("c1", func() (float64, error) { return 0, nil })
+	}
+	if time.Since(start) < 90*time.Millisecond {
+		t.Error("fills were not rate limited")
+	}
+	assertInt(t, 2, scheduler.Stats()["c1"].RateLimited, "wrong number of rate limited fills")
+}
+
+// Check that a quiet caller doesn't have to wait behind all the fills of a noisy one
+func TestFairScheduler_SharesCapacityBetweenCallers(t *testing.T) {
+	scheduler := NewFairScheduler(1, CallerQuota{})
+	blocker := make(chan struct{})
+	order := []string{}
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	do := func(caller string) {
+		defer wg.Done()
+		scheduler.Do(caller, func() (float64, error) {
+			mu.Lock()
+			order = append(order, caller)
+			mu.Unlock()
+			return 0, nil
+		})
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scheduler.Do("blocker", func() (float64, error) {
+			<-blocker
+			return 0, nil
+		})
+	}()
+	waitForStats(t, scheduler, "blocker", func(stats CallerStats) bool { return stats.InFlight == 1 })
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go do("noisy")
+		waitForStats(t, scheduler, "noisy", queued(i))
+	}
+	wg.Add(1)
+	go do("quiet")
+	waitForStats(t, scheduler, "quiet", queued(1))
+	close(blocker)
+	wg.Wait()
+
+	expected := []string{"noisy", "quiet", "noisy", "noisy"}
+	for i, caller := range expected {
+		if order[i] != caller {
+			t.Fatalf("wrong dispatch order, expected : %v, got : %v", expected, order)
+		}
+	}
+}
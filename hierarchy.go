@@ -0,0 +1,144 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPriceNotFound is returned (or wrapped) by a PriceService that has no price for the item
+var ErrPriceNotFound = errors.New("price not found")
+
+// ItemHierarchy gives the parent of an item code, for items that inherit the price of their parent
+type ItemHierarchy interface {
+	Parent(itemCode string) (string, bool)
+}
+
+// SeparatorHierarchy finds the parent by removing the last segment of the item code
+// With "-" and at least 2 segments, the parent of "SKU-123-RED-L" is "SKU-123-RED", whose parent is "SKU-123"
+type SeparatorHierarchy struct {
+	Separator   string
+	MinSegments int // item codes with this many segments have no parent
+}
+
+// Parent returns the item code without its last segment
+func (h SeparatorHierarchy) Parent(itemCode string) (string, bool) {
+	segments := strings.Split(itemCode, h.Separator)
+	if len(segments) <= h.MinSegments || len(segments) < 2 {
+		return "", false
+	}
+	return strings.Join(segments[:len(segments)-1], h.Separator), true
+}
+
+// ParentMap is an explicit hierarchy, the parent of each item code
+type ParentMap map[string]string
+
+// Parent returns the parent of the item code in the map
+func (h ParentMap) Parent(itemCode string) (string, bool) {
+	parent, ok := h[itemCode]
+	return parent, ok
+}
+
+// WithHierarchy makes the items the actual service has no price for inherit the price of their closest ancestor
+func WithHierarchy(hierarchy ItemHierarchy) Option {
+	return func(c *TransparentCache) {
+		c.hierarchy = hierarchy
+	}
+}
+
+// GetPriceWithSourceFor is GetPriceFor also returning the item code the price belongs to
+// It is the item code itself, or the ancestor it was inherited from
+func (c *TransparentCache) GetPriceWithSourceFor(itemCode string) (float64, string, error) {
+	priceItem, err := c.getPriceItem(DefaultCaller, itemCode)
+	if err != nil {
+		return 0, "", err
+	}
+	return priceItem.price, sourceOf(itemCode, priceItem), nil
+}
+
+// inherit walks up the hierarchy of the item until an ancestor has a price, caching the ancestor and the item
+// notFound is returned if no ancestor has a price
+func (c *TransparentCache) inherit(caller string, itemCode string, notFound error, trace *LookupTrace) (*PriceItem, error) {
+	visited := map[string]bool{itemCode: true}
+	current := itemCode
+	for {
+		parent, ok := c.hierarchy.Parent(current)
+		if !ok || visited[parent] {
+			return nil, notFound
+		}
+		visited[parent] = true
+		trace.rule(fmt.Sprintf("%v has no price, trying its parent %v", current, parent))
+
+		parentItem, ok := c.cachedItem(parent)
+		if !ok {
+			price, unit, err := c.tracedFill(caller, parent, trace)
+			if err != nil {
+				if !errors.Is(err, ErrPriceNotFound) {
+					return nil, err
+				}
+				current = parent
+				continue
+			}
+			parentItem = c.store(parent, price, unit)
+		}
+		priceItem, stored := c.storeInherited(itemCode, parent, parentItem)
+		if !stored {
+			trace.rule(fmt.Sprintf("%v changed while inheriting its price, served without caching it", parent))
+		}
+		return priceItem, nil
+	}
+}
+
+// storeInherited caches the item inheriting the price of the ancestor entry, it returns if it was cached
+// It is only cached if the entry is still the cached one of the ancestor, checking it and registering the inheritor
+// under the same lock means any later change of the ancestor invalidates the item
+func (c *TransparentCache) storeInherited(itemCode string, ancestor string, ancestorItem *PriceItem) (*PriceItem, bool) {
+	priceItem := inheritedFrom(ancestor, ancestorItem)
+	c.mu.Lock()
+	if c.prices[ancestor] != ancestorItem {
+		c.mu.Unlock()
+		return priceItem, false
+	}
+	c.prices[itemCode] = priceItem
+	c.inheritors[priceItem.source] = append(without(c.inheritors[priceItem.source], itemCode), itemCode)
+	c.mu.Unlock()
+	c.ancestorChanged(itemCode)
+	c.componentChanged(itemCode)
+	return priceItem, true
+}
+
+// inheritedFrom is the entry of an item inheriting the price of the cached ancestor
+// It is as old as the price of the ancestor, so it expires with it
+func inheritedFrom(ancestor string, ancestorItem *PriceItem) *PriceItem {
+	return &PriceItem{
+		dateCreated: ancestorItem.dateCreated,
+		price:       ancestorItem.price,
+		unit:        ancestorItem.unit,
+		source:      sourceOf(ancestor, ancestorItem),
+	}
+}
+
+// ancestorChanged invalidates the cached items that inherited their price from the item, they inherit it again on
+// their next lookup
+func (c *TransparentCache) ancestorChanged(itemCode string) {
+	c.mu.Lock()
+	inheritors := []string{}
+	for _, inheritor := range c.inheritors[itemCode] {
+		if priceItem, ok := c.prices[inheritor]; ok && priceItem.source == itemCode {
+			inheritors = append(inheritors, inheritor)
+		}
+	}
+	delete(c.inheritors, itemCode)
+	c.mu.Unlock()
+	for _, inheritor := range inheritors {
+		c.Invalidate(inheritor)
+	}
+}
+
+// sourceOf returns the item code the price of the cached item belongs to
+func sourceOf(itemCode string, priceItem *PriceItem) string {
+	if priceItem.source == "" {
+		return itemCode
+	}
+	return priceItem.source
+}
@@ -0,0 +1,125 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Check that a variant without a price inherits it from the closest ancestor and both are cached
+func TestGetPriceWithSourceFor_InheritsFromAncestor(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"SKU-123-RED-L": {price: 0, err: ErrPriceNotFound},
+			"SKU-123-RED":   {price: 0, err: fmt.Errorf("not in catalog : %w", ErrPriceNotFound)},
+			"SKU-123":       {price: 10, err: nil},
+			"SKU-123-BLUE":  {price: 0, err: ErrPriceNotFound},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithHierarchy(SeparatorHierarchy{Separator: "-", MinSegments: 2}))
+	price, source, err := cache.GetPriceWithSourceFor("SKU-123-RED-L")
+	if err != nil {
+		t.Fatal("error getting price", err)
+	}
+	assertFloat(t, 10, price, "wrong price returned")
+	if source != "SKU-123" {
+		t.Error("wrong source", source)
+	}
+	assertInt(t, 3, mockService.getNumCalls(), "wrong number of service calls")
+
+	// both the variant and the ancestor are cached now
+	cache.GetPriceFor("SKU-123-RED-L")
+	_, source, _ = cache.GetPriceWithSourceFor("SKU-123")
+	if source != "SKU-123" {
+		t.Error("wrong source", source)
+	}
+	assertFloat(t, 10, getPriceWithNoErr(t, cache, "SKU-123-BLUE"), "wrong price returned")
+	assertInt(t, 4, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that not-found is returned when no ancestor has a price, and other errors stop the walk
+func TestGetPriceFor_HierarchyErrors(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"v1": {price: 0, err: ErrPriceNotFound},
+			"v2": {price: 0, err: ErrPriceNotFound},
+			"p1": {price: 0, err: ErrPriceNotFound},
+			"p2": {price: 0, err: fmt.Errorf("some error")},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithHierarchy(ParentMap{"v1": "p1", "v2": "p2", "p1": "v1"}))
+	if _, err := cache.GetPriceFor("v1"); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if _, err := cache.GetPriceFor("v2"); err == nil || errors.Is(err, ErrPriceNotFound) {
+		t.Error("expected service error, got", err)
+	}
+}
+
+// Check that inherited items expire with their ancestor and are invalidated when the ancestor changes
+func TestGetPriceFor_InheritedItemsFollowTheirAncestor(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"v1": {price: 0, err: ErrPriceNotFound},
+			"v2": {price: 0, err: ErrPriceNotFound},
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, 50*time.Millisecond, WithHierarchy(ParentMap{"v1": "p1", "v2": "p1"}))
+	getPriceWithNoErr(t, cache, "p1")
+	time.Sleep(30 * time.Millisecond)
+
+	// v1 inherits the cached price of p1, so it is as old as it
+	assertFloat(t, 5, getPriceWithNoErr(t, cache, "v1"), "wrong price returned")
+	ttl, _ := cache.remainingTTL("v1")
+	if ttl > 20*time.Millisecond {
+		t.Error("inherited item outlives its ancestor, remaining ttl", ttl)
+	}
+
+	// refreshing the ancestor invalidates the items that inherited its price
+	mockService.mockResults["p1"] = mockResult{price: 6, err: nil}
+	if _, err := cache.Refresh("p1"); err != nil {
+		t.Fatal("error refreshing price", err)
+	}
+	if _, ok := cache.cachedItem("v1"); ok {
+		t.Error("inherited item not invalidated when its ancestor was refreshed")
+	}
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "v1"), "wrong price returned")
+
+	// and so does invalidating it
+	getPriceWithNoErr(t, cache, "v2")
+	cache.Invalidate("p1")
+	if _, ok := cache.cachedItem("v1"); ok {
+		t.Error("inherited item not invalidated with its ancestor")
+	}
+	if _, ok := cache.cachedItem("v2"); ok {
+		t.Error("inherited item not invalidated with its ancestor")
+	}
+}
+
+// Check that an item is not cached with the price of an ancestor entry that changed while inheriting it
+func TestGetPriceFor_InheritingFromAChangedAncestor(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"v1": {price: 0, err: ErrPriceNotFound},
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithHierarchy(ParentMap{"v1": "p1"}))
+	getPriceWithNoErr(t, cache, "p1")
+	parentItem, _ := cache.cachedItem("p1")
+
+	// p1 is refreshed after v1 read it but before v1 is stored
+	mockService.mockResults["p1"] = mockResult{price: 6, err: nil}
+	cache.Refresh("p1")
+	priceItem, stored := cache.storeInherited("v1", "p1", parentItem)
+	if stored {
+		t.Error("item cached with the price of a changed ancestor")
+	}
+	assertFloat(t, 5, priceItem.price, "wrong inherited price returned")
+	if _, ok := cache.cachedItem("v1"); ok {
+		t.Error("item cached with the price of a changed ancestor")
+	}
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "v1"), "wrong price returned")
+}
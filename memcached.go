@@ -0,0 +1,123 @@
+package sample1
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Limits of a request, like in memcached keys are at most 250 bytes
+const (
+	maxMemcachedKeySize  = 250
+	maxMemcachedKeys     = 100
+	maxMemcachedLineSize = 32 * 1024
+)
+
+// MemcachedServer serves the prices in the cache with the memcached text protocol
+// Only the get, gets, delete and quit commands are supported, misses are filled from the actual service
+// Keys the actual service has no price for are left out of a get, like misses in memcached
+// If the actual service fails for any other key the whole get fails with SERVER_ERROR
+type MemcachedServer struct {
+	*connServer
+	cache *TransparentCache
+}
+
+// NewMemcachedServer creates a memcached front-end for the cache, call Serve to start accepting connections
+func NewMemcachedServer(cache *TransparentCache) *MemcachedServer {
+	server := &MemcachedServer{cache: cache}
+	server.connServer = newConnServer(server.handle)
+	return server
+}
+
+func (s *MemcachedServer) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(reader, maxMemcachedLineSize)
+		if err == errLineTooLong {
+			// the rest of the line is still in the stream, so the connection can't be used anymore
+			writer.WriteString("CLIENT_ERROR line too long\r\n")
+			writer.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			writer.WriteString("ERROR\r\n")
+			writer.Flush()
+			continue
+		}
+		switch fields[0] {
+		case "get", "gets":
+			s.get(writer, fields[1:], fields[0] == "gets")
+		case "delete":
+			s.delete(writer, fields[1:])
+		case "quit":
+			return
+		default:
+			writer.WriteString("ERROR\r\n")
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// get writes a VALUE line for each key, gets also writes the creation date of the entry as its cas unique
+func (s *MemcachedServer) get(writer *bufio.Writer, keys []string, withCas bool) {
+	if len(keys) == 0 {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	if len(keys) > maxMemcachedKeys {
+		writer.WriteString("CLIENT_ERROR too many keys\r\n")
+		return
+	}
+	for _, key := range keys {
+		if len(key) > maxMemcachedKeySize {
+			writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+	}
+	items, errs := s.cache.getPriceItems(DefaultCaller, keys)
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrPriceNotFound) {
+			fmt.Fprintf(writer, "SERVER_ERROR %v\r\n", err.Error())
+			return
+		}
+	}
+	for i, key := range keys {
+		if errs[i] != nil {
+			continue
+		}
+		data := strconv.FormatFloat(items[i].price, 'f', -1, 64)
+		if withCas {
+			fmt.Fprintf(writer, "VALUE %v 0 %v %v\r\n%v\r\n", key, len(data), items[i].dateCreated.UnixNano(), data)
+		} else {
+			fmt.Fprintf(writer, "VALUE %v 0 %v\r\n%v\r\n", key, len(data), data)
+		}
+	}
+	writer.WriteString("END\r\n")
+}
+
+func (s *MemcachedServer) delete(writer *bufio.Writer, args []string) {
+	if len(args) == 0 {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := args[len(args)-1] == "noreply"
+	deleted := s.cache.Invalidate(args[0])
+	if noreply {
+		return
+	}
+	if deleted {
+		writer.WriteString("DELETED\r\n")
+	} else {
+		writer.WriteString("NOT_FOUND\r\n")
+	}
+}
@@ -0,0 +1,143 @@
+package sample1
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startMemcachedServer serves the cache on a local port and returns a connected client
+func startMemcachedServer(t *testing.T, cache *TransparentCache) (net.Conn, *bufio.Reader) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error listening", err)
+	}
+	server := NewMemcachedServer(cache)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal("error connecting", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+// sendMemcached sends the command and reads lines until the terminator line
+func sendMemcached(t *testing.T, conn net.Conn, reader *bufio.Reader, command string, terminators ...string) []string {
+	if _, err := fmt.Fprintf(conn, "%v\r\n", command); err != nil {
+		t.Fatal("error sending command", err)
+	}
+	lines := []string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal("error reading response", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		for _, terminator := range terminators {
+			if strings.HasPrefix(line, terminator) {
+				return lines
+			}
+		}
+	}
+}
+
+// Check that get fills from the actual service and returns every key in order
+func TestMemcachedServer_Get(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7.5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	conn, reader := startMemcachedServer(t, cache)
+
+	lines := sendMemcached(t, conn, reader, "get p1 p2", "END", "SERVER_ERROR")
+	expected := []string{"VALUE p1 0 1", "5", "VALUE p2 0 3", "7.5", "END"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong response, expected : %v, got : %v", expected, lines)
+	}
+	sendMemcached(t, conn, reader, "get p1", "END")
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+
+	lines = sendMemcached(t, conn, reader, "gets p1", "END")
+	if len(lines) != 3 || len(strings.Fields(lines[0])) != 5 {
+		t.Error("expected cas unique in gets response, got", lines)
+	}
+}
+
+// Check that delete invalidates the item and that service errors are reported
+func TestMemcachedServer_DeleteAndErrors(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 0, err: fmt.Errorf("some error")},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	conn, reader := startMemcachedServer(t, cache)
+
+	sendMemcached(t, conn, reader, "get p1", "END")
+	if lines := sendMemcached(t, conn, reader, "delete p1", "DELETED", "NOT_FOUND"); lines[0] != "DELETED" {
+		t.Error("expected DELETED, got", lines)
+	}
+	if lines := sendMemcached(t, conn, reader, "delete p1", "DELETED", "NOT_FOUND"); lines[0] != "NOT_FOUND" {
+		t.Error("expected NOT_FOUND, got", lines)
+	}
+	if lines := sendMemcached(t, conn, reader, "get p1 p2", "END", "SERVER_ERROR"); !strings.HasPrefix(lines[0], "SERVER_ERROR") {
+		t.Error("expected SERVER_ERROR, got", lines)
+	}
+	if lines := sendMemcached(t, conn, reader, "set p1 0 0 1", "ERROR"); lines[0] != "ERROR" {
+		t.Error("expected ERROR, got", lines)
+	}
+}
+
+// Check that oversized requests are rejected before calling the actual service
+func TestMemcachedServer_RejectsOversizedRequests(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	conn, reader := startMemcachedServer(t, cache)
+
+	keys := strings.Repeat("p1 ", maxMemcachedKeys+1)
+	if lines := sendMemcached(t, conn, reader, "get "+keys, "END", "CLIENT_ERROR"); lines[0] != "CLIENT_ERROR too many keys" {
+		t.Error("expected too many keys error, got", lines)
+	}
+	longKey := strings.Repeat("k", maxMemcachedKeySize+1)
+	if lines := sendMemcached(t, conn, reader, "get "+longKey, "END", "CLIENT_ERROR"); lines[0] != "CLIENT_ERROR bad command line format" {
+		t.Error("expected bad format error, got", lines)
+	}
+	assertInt(t, 0, mockService.getNumCalls(), "wrong number of service calls")
+
+	longLine := "get " + strings.Repeat("p", maxMemcachedLineSize)
+	if lines := sendMemcached(t, conn, reader, longLine, "END", "CLIENT_ERROR"); lines[0] != "CLIENT_ERROR line too long" {
+		t.Error("expected line too long error, got", lines)
+	}
+}
+
+// Check that keys the actual service has no price for are misses, left out of the get
+func TestMemcachedServer_NotFoundKeysAreMisses(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 0, err: fmt.Errorf("not in catalog : %w", ErrPriceNotFound)},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	conn, reader := startMemcachedServer(t, cache)
+
+	lines := sendMemcached(t, conn, reader, "get p2 p1", "END", "SERVER_ERROR")
+	expected := []string{"VALUE p1 0 1", "5", "END"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong response, expected : %v, got : %v", expected, lines)
+	}
+}
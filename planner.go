@@ -0,0 +1,157 @@
+package sample1
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// accessEvent is a lookup of the item at a moment
+type accessEvent struct {
+	itemCode string
+	at       time.Time
+}
+
+// CapacitySampler records a sample of the lookups of the cache to replay them against other sizes (a ghost cache)
+// The sample is taken by item code hash, so every lookup of a sampled item is recorded
+type CapacitySampler struct {
+	sampleRate float64
+	maxEvents  int
+	events     []accessEvent
+	next       int // where the next event goes once events is full
+	mu         *sync.Mutex
+}
+
+// NewCapacitySampler creates a sampler for sampleRate (from 0 to 1) of the items keeping the last maxEvents lookups
+// A maxEvents of 0 or less records nothing
+func NewCapacitySampler(sampleRate float64, maxEvents int) *CapacitySampler {
+	if maxEvents < 0 {
+		maxEvents = 0
+	}
+	return &CapacitySampler{
+		sampleRate: sampleRate,
+		maxEvents:  maxEvents,
+		mu:         &sync.Mutex{},
+	}
+}
+
+// WithCapacitySampler makes the cache record its lookups in the sampler
+func WithCapacitySampler(sampler *CapacitySampler) Option {
+	return func(c *TransparentCache) {
+		c.capacitySampler = sampler
+	}
+}
+
+// Record records a lookup of the item if it is part of the sample
+func (s *CapacitySampler) Record(itemCode string, at time.Time) {
+	if s.maxEvents == 0 || !sampledByHash(itemCode, s.sampleRate) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) < s.maxEvents {
+		s.events = append(s.events, accessEvent{itemCode: itemCode, at: at})
+		return
+	}
+	s.events[s.next] = accessEvent{itemCode: itemCode, at: at}
+	s.next = (s.next + 1) % s.maxEvents
+}
+
+// trace returns the recorded events, oldest first
+func (s *CapacitySampler) trace() []accessEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append(append([]accessEvent{}, s.events[s.next:]...), s.events[:s.next]...)
+}
+
+// CapacityScenario is the estimated behavior of the cache with a capacity (0 means unbounded) and maxAge
+type CapacityScenario struct {
+	Capacity               int
+	MaxAge                 time.Duration
+	HitRatio               float64
+	UpstreamCallsPerSecond float64
+}
+
+// CapacityReport compares the current behavior of the cache with other capacities and maxAge values
+type CapacityReport struct {
+	Entries         int
+	MaxAge          time.Duration
+	CurrentHitRatio float64 // from the TTL stats of the cache
+	SampledLookups  int
+	SampledPeriod   time.Duration
+	Scenarios       []CapacityScenario
+}
+
+// PlanCapacity estimates the hit ratio and upstream call rate of the cache for every capacity and maxAge combination
+// It needs a CapacitySampler, without it the report only has the current state of the cache
+func (c *TransparentCache) PlanCapacity(capacities []int, maxAges []time.Duration) CapacityReport {
+	stats := c.TTLStats()
+	c.mu.Lock()
+	report := CapacityReport{Entries: len(c.prices), MaxAge: c.maxAge}
+	c.mu.Unlock()
+	if stats.Lookups > 0 {
+		report.CurrentHitRatio = float64(stats.FreshHits+stats.SoftHits) / float64(stats.Lookups)
+	}
+	if c.capacitySampler == nil {
+		return report
+	}
+	trace := c.capacitySampler.trace()
+	report.SampledLookups = len(trace)
+	if len(trace) == 0 {
+		return report
+	}
+	report.SampledPeriod = trace[len(trace)-1].at.Sub(trace[0].at)
+	for _, capacity := range capacities {
+		for _, maxAge := range maxAges {
+			report.Scenarios = append(report.Scenarios, simulateCapacity(trace, c.capacitySampler.sampleRate, capacity, maxAge, report.SampledPeriod))
+		}
+	}
+	return report
+}
+
+// ghostEntry is an entry of the simulated cache, it only knows when it was filled
+type ghostEntry struct {
+	itemCode string
+	filled   time.Time
+}
+
+// simulateCapacity replays the trace against an LRU cache with the capacity scaled down to the sample
+func simulateCapacity(trace []accessEvent, sampleRate float64, capacity int, maxAge time.Duration, period time.Duration) CapacityScenario {
+	scaled := 0
+	if capacity > 0 {
+		scaled = int(math.Max(1, math.Round(float64(capacity)*math.Min(sampleRate, 1))))
+	}
+	lru := list.New()
+	entries := map[string]*list.Element{}
+	hits := 0
+	for _, event := range trace {
+		element, ok := entries[event.itemCode]
+		if ok && event.at.Sub(element.Value.(*ghostEntry).filled) < maxAge {
+			hits++
+			lru.MoveToFront(element)
+			continue
+		}
+		if ok {
+			element.Value.(*ghostEntry).filled = event.at
+			lru.MoveToFront(element)
+			continue
+		}
+		entries[event.itemCode] = lru.PushFront(&ghostEntry{itemCode: event.itemCode, filled: event.at})
+		if scaled > 0 && lru.Len() > scaled {
+			oldest := lru.Back()
+			lru.Remove(oldest)
+			delete(entries, oldest.Value.(*ghostEntry).itemCode)
+		}
+	}
+	scenario := CapacityScenario{
+		Capacity: capacity,
+		MaxAge:   maxAge,
+		HitRatio: float64(hits) / float64(len(trace)),
+	}
+	if period > 0 && sampleRate > 0 {
+		misses := float64(len(trace) - hits)
+		scenario.UpstreamCallsPerSecond = misses / math.Min(sampleRate, 1) / period.Seconds()
+	}
+	return scenario
+}
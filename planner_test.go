@@ -0,0 +1,61 @@
+package sample1
+
+import (
+	"testing"
+	"time"
+)
+
+// Check that the planner replays the sampled lookups against other capacities and maxAge values
+func TestPlanCapacity_EstimatesScenarios(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+			"p3": {price: 9, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithCapacitySampler(NewCapacitySampler(1, 100)))
+	// p1 p2 p3 cycling twice: an unbounded cache hits the second round, an LRU of 2 never hits
+	for i := 0; i < 2; i++ {
+		for _, itemCode := range []string{"p1", "p2", "p3"} {
+			getPriceWithNoErr(t, cache, itemCode)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	getPriceWithNoErr(t, cache, "p1")
+
+	report := cache.PlanCapacity([]int{0, 2}, []time.Duration{time.Minute, 10 * time.Millisecond})
+	assertInt(t, 3, report.Entries, "wrong number of entries")
+	assertInt(t, 7, report.SampledLookups, "wrong number of sampled lookups")
+	assertAlmostFloat(t, 4.0/7.0, report.CurrentHitRatio, "wrong current hit ratio")
+	assertInt(t, 4, len(report.Scenarios), "wrong number of scenarios")
+
+	expected := []float64{4.0 / 7.0, 3.0 / 7.0, 0, 0}
+	for i, scenario := range report.Scenarios {
+		assertAlmostFloat(t, expected[i], scenario.HitRatio, "wrong hit ratio")
+	}
+	if report.Scenarios[2].UpstreamCallsPerSecond <= report.Scenarios[0].UpstreamCallsPerSecond {
+		t.Error("expected a smaller cache to call the upstream more", report.Scenarios)
+	}
+}
+
+// Check that the sampler keeps only the last events
+func TestCapacitySampler_KeepsLastEvents(t *testing.T) {
+	sampler := NewCapacitySampler(1, 2)
+	start := time.Now()
+	for i, itemCode := range []string{"p1", "p2", "p3"} {
+		sampler.Record(itemCode, start.Add(time.Duration(i)*time.Second))
+	}
+	trace := sampler.trace()
+	if len(trace) != 2 || trace[0].itemCode != "p2" || trace[1].itemCode != "p3" {
+		t.Error("wrong events kept", trace)
+	}
+}
+
+// Check that a sampler without room for events or with a zero rate records nothing
+func TestCapacitySampler_RecordsNothingWithoutRoomOrRate(t *testing.T) {
+	for _, sampler := range []*CapacitySampler{NewCapacitySampler(1, 0), NewCapacitySampler(1, -1), NewCapacitySampler(0, 10)} {
+		sampler.Record("p1", time.Now())
+		assertInt(t, 0, len(sampler.trace()), "wrong number of events kept")
+	}
+}
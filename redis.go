@@ -0,0 +1,194 @@
+package sample1
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of a request, anything bigger is a protocol error
+// Item codes are much smaller than a bulk, and commands never need more arguments than a big batch
+// maxRESPCommandSize bounds the bulks of a whole command, so a command can't make us buffer much more than that
+const (
+	maxRESPBulkSize    = 64 * 1024
+	maxRESPArgs        = 1024
+	maxRESPLineSize    = maxRESPBulkSize
+	maxRESPCommandSize = 1024 * 1024
+)
+
+// maxRESPKeys is the amount of keys of a MGET or DEL, each key of a MGET can be an upstream call
+const maxRESPKeys = 256
+
+// RedisServer serves the prices in the cache with the Redis RESP2 protocol
+// It supports GET, MGET, DEL, TTL, PING, QUIT and PRICE.REFRESH, which gets the price from the actual service again
+// Misses are filled from the actual service, TTL reports the remaining lifetime of the entry and never fills it
+type RedisServer struct {
+	*connServer
+	cache *TransparentCache
+}
+
+// NewRedisServer creates a Redis front-end for the cache, call Serve to start accepting connections
+func NewRedisServer(cache *TransparentCache) *RedisServer {
+	server := &RedisServer{cache: cache}
+	server.connServer = newConnServer(server.handle)
+	return server
+}
+
+func (s *RedisServer) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			var protocolErr respProtocolError
+			if errors.As(err, &protocolErr) {
+				writeRESPError(writer, protocolErr.Error())
+				writer.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(writer, strings.ToUpper(args[0]), args[1:])
+		if err := writer.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// execute writes the reply of the command, it returns true if the connection has to be closed
+func (s *RedisServer) execute(writer *bufio.Writer, command string, args []string) bool {
+	switch {
+	case command == "PING":
+		writer.WriteString("+PONG\r\n")
+	case command == "QUIT":
+		writer.WriteString("+OK\r\n")
+		return true
+	case command == "GET" && len(args) == 1:
+		price, err := s.cache.GetPriceFor(args[0])
+		if err != nil {
+			writeRESPError(writer, err.Error())
+			break
+		}
+		writeRESPPrice(writer, price)
+	case (command == "MGET" || command == "DEL") && len(args) > maxRESPKeys:
+		writeRESPError(writer, fmt.Sprintf("too many keys for '%v' command, the limit is %v", strings.ToLower(command), maxRESPKeys))
+	case command == "MGET" && len(args) > 0:
+		s.mget(writer, args)
+	case command == "DEL" && len(args) > 0:
+		deleted := 0
+		for _, itemCode := range args {
+			if s.cache.Invalidate(itemCode) {
+				deleted++
+			}
+		}
+		fmt.Fprintf(writer, ":%v\r\n", deleted)
+	case command == "TTL" && len(args) == 1:
+		ttl, ok := s.cache.remainingTTL(args[0])
+		if !ok {
+			writer.WriteString(":-2\r\n")
+			break
+		}
+		// round up so an entry that is still served never reports 0
+		fmt.Fprintf(writer, ":%v\r\n", int64((ttl+time.Second-1)/time.Second))
+	case command == "PRICE.REFRESH" && len(args) == 1:
+		price, err := s.cache.Refresh(args[0])
+		if err != nil {
+			writeRESPError(writer, err.Error())
+			break
+		}
+		writeRESPPrice(writer, price)
+	case command == "GET" || command == "MGET" || command == "DEL" || command == "TTL" || command == "PRICE.REFRESH":
+		writeRESPError(writer, fmt.Sprintf("wrong number of arguments for '%v' command", strings.ToLower(command)))
+	default:
+		writeRESPError(writer, fmt.Sprintf("unknown command '%v'", command))
+	}
+	return false
+}
+
+// mget looks up the keys in parallel, the keys for which the actual service fails are returned as nil
+func (s *RedisServer) mget(writer *bufio.Writer, itemCodes []string) {
+	priceItems, errs := s.cache.getPriceItems(DefaultCaller, itemCodes)
+	fmt.Fprintf(writer, "*%v\r\n", len(itemCodes))
+	for i := range itemCodes {
+		if errs[i] != nil {
+			writer.WriteString("$-1\r\n")
+			continue
+		}
+		writeRESPPrice(writer, priceItems[i].price)
+	}
+}
+
+func writeRESPPrice(writer *bufio.Writer, price float64) {
+	data := strconv.FormatFloat(price, 'f', -1, 64)
+	fmt.Fprintf(writer, "$%v\r\n%v\r\n", len(data), data)
+}
+
+func writeRESPError(writer *bufio.Writer, msg string) {
+	// errors are a single line in RESP
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	fmt.Fprintf(writer, "-ERR %v\r\n", msg)
+}
+
+// respProtocolError is a malformed request, it is reported to the client before closing the connection
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// readRESPCommand reads a command sent as an array of bulk strings, or as an inline command
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxRESPArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+	// args grows as they are read, so the count alone can't make us allocate
+	args := []string{}
+	total := 0
+	for i := 0; i < count; i++ {
+		header, err := readRESPLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got '%v'", header))
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 || size > maxRESPBulkSize {
+			return nil, respProtocolError("invalid bulk length")
+		}
+		total += size
+		if total > maxRESPCommandSize {
+			return nil, respProtocolError("too big command")
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+// readRESPLine reads a line of at most maxRESPLineSize bytes
+func readRESPLine(reader *bufio.Reader) (string, error) {
+	line, err := readLine(reader, maxRESPLineSize)
+	if err == errLineTooLong {
+		return "", respProtocolError("too big request line")
+	}
+	return line, err
+}
@@ -0,0 +1,162 @@
+package sample1
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// respClient is a minimal RESP2 client, replies are returned as strings, integers, nil or slices of them
+type respClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (c *respClient) do(t *testing.T, args ...string) interface{} {
+	command := fmt.Sprintf("*%v\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%v\r\n%v\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, command); err != nil {
+		t.Fatal("error sending command", err)
+	}
+	return c.readReply(t)
+}
+
+func (c *respClient) readReply(t *testing.T) interface{} {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		t.Fatal("error reading reply", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch line[0] {
+	case '+', '-':
+		return line
+	case ':':
+		n, _ := strconv.Atoi(line[1:])
+		return n
+	case '$':
+		size, _ := strconv.Atoi(line[1:])
+		if size < 0 {
+			return nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			t.Fatal("error reading reply", err)
+		}
+		return string(data[:size])
+	case '*':
+		count, _ := strconv.Atoi(line[1:])
+		replies := []interface{}{}
+		for i := 0; i < count; i++ {
+			replies = append(replies, c.readReply(t))
+		}
+		return replies
+	}
+	t.Fatal("unexpected reply", line)
+	return nil
+}
+
+func startRedisServer(t *testing.T, cache *TransparentCache) *respClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error listening", err)
+	}
+	server := NewRedisServer(cache)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal("error connecting", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &respClient{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func assertReply(t *testing.T, expected interface{}, actual interface{}, msg string) {
+	if fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Error(msg, fmt.Sprintf("expected : %v, got : %v", expected, actual))
+	}
+}
+
+// Check that GET and MGET fill from the actual service and DEL invalidates
+func TestRedisServer_GetMGetDel(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7.5, err: nil},
+			"p3": {price: 0, err: fmt.Errorf("some error")},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	client := startRedisServer(t, cache)
+
+	assertReply(t, "5", client.do(t, "GET", "p1"), "wrong GET reply")
+	assertReply(t, []interface{}{"5", "7.5", nil}, client.do(t, "mget", "p1", "p2", "p3"), "wrong MGET reply")
+	assertInt(t, 3, mockService.getNumCalls(), "wrong number of service calls")
+	if reply := client.do(t, "GET", "p3"); !strings.HasPrefix(reply.(string), "-ERR") {
+		t.Error("expected error reply, got", reply)
+	}
+	assertReply(t, 2, client.do(t, "DEL", "p1", "p2", "p4"), "wrong DEL reply")
+	assertReply(t, -2, client.do(t, "TTL", "p1"), "wrong TTL reply")
+	assertReply(t, "+PONG", client.do(t, "PING"), "wrong PING reply")
+	if reply := client.do(t, "SET", "p1", "1"); !strings.HasPrefix(reply.(string), "-ERR unknown command") {
+		t.Error("expected unknown command reply, got", reply)
+	}
+}
+
+// Check that TTL reports the remaining lifetime and PRICE.REFRESH resets it
+func TestRedisServer_TTLAndRefresh(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, 10*time.Second)
+	client := startRedisServer(t, cache)
+
+	assertReply(t, -2, client.do(t, "TTL", "p1"), "wrong TTL reply")
+	assertInt(t, 0, mockService.getNumCalls(), "TTL should not call the service")
+	client.do(t, "GET", "p1")
+	assertReply(t, 10, client.do(t, "TTL", "p1"), "wrong TTL reply")
+
+	mockService.mockResults["p1"] = mockResult{price: 6, err: nil}
+	assertReply(t, "6", client.do(t, "PRICE.REFRESH", "p1"), "wrong PRICE.REFRESH reply")
+	assertReply(t, "6", client.do(t, "GET", "p1"), "wrong GET reply")
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that oversized requests are rejected before allocating for them
+func TestRedisServer_RejectsOversizedRequests(t *testing.T) {
+	cache := NewTransparentCache(&mockPriceService{}, time.Minute)
+
+	client := startRedisServer(t, cache)
+	io.WriteString(client.conn, "*9000000000000\r\n")
+	assertReply(t, "-ERR Protocol error: invalid multibulk length", client.readReply(t), "wrong reply")
+
+	client = startRedisServer(t, cache)
+	io.WriteString(client.conn, "*1\r\n$9000000000000\r\n")
+	assertReply(t, "-ERR Protocol error: invalid bulk length", client.readReply(t), "wrong reply")
+
+	client = startRedisServer(t, cache)
+	io.WriteString(client.conn, "GET "+strings.Repeat("p", maxRESPLineSize)+"\r\n")
+	assertReply(t, "-ERR Protocol error: too big request line", client.readReply(t), "wrong reply")
+
+	// every bulk is within its limit but the whole command is not
+	client = startRedisServer(t, cache)
+	bulk := strings.Repeat("p", maxRESPBulkSize)
+	io.WriteString(client.conn, fmt.Sprintf("*%v\r\n", maxRESPArgs))
+	for i := 0; i <= maxRESPCommandSize/maxRESPBulkSize; i++ {
+		io.WriteString(client.conn, fmt.Sprintf("$%v\r\n%v\r\n", len(bulk), bulk))
+	}
+	assertReply(t, "-ERR Protocol error: too big command", client.readReply(t), "wrong reply")
+
+	client = startRedisServer(t, cache)
+	io.WriteString(client.conn, "MGET"+strings.Repeat(" p", maxRESPKeys+1)+"\r\n")
+	assertReply(t, "-ERR too many keys for 'mget' command, the limit is 256", client.readReply(t), "wrong reply")
+}
@@ -0,0 +1,165 @@
+package sample1
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchPriceService is a PriceService that can also get the prices for several items in one call
+// Prices are returned in the same order as the item codes
+type BatchPriceService interface {
+	PriceService
+	GetPricesFor(itemCodes ...string) ([]float64, error)
+}
+
+// RefreshResult is the outcome of refreshing the whole cache once
+type RefreshResult struct {
+	Refreshed int
+	Failed    int
+	Duration  time.Duration
+}
+
+// Refresher re-fetches every entry in the cache on a schedule instead of waiting for them to expire
+// If a refresh fails the old value is left in place
+type Refresher struct {
+	cache       *TransparentCache
+	interval    time.Duration
+	batchSize   int
+	concurrency int
+	lastResult  *RefreshResult
+	mu          *sync.Mutex
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// NewRefresher creates a refresher for the cache that runs every interval
+// Items are fetched in batches of batchSize, with at most concurrency batches at the same time
+func NewRefresher(cache *TransparentCache, interval time.Duration, batchSize int, concurrency int) *Refresher {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Refresher{
+		cache:       cache,
+		interval:    interval,
+		batchSize:   batchSize,
+		concurrency: concurrency,
+		lastResult:  &RefreshResult{},
+		mu:          &sync.Mutex{},
+	}
+}
+
+// Start runs the refresh in the background every interval until Stop is called
+func (r *Refresher) Start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.RefreshAll()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background refresh and waits for the current one to finish
+func (r *Refresher) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// LastResult returns the result of the last refresh
+func (r *Refresher) LastResult() RefreshResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.lastResult
+}
+
+// RefreshAll re-fetches every item in the cache once
+func (r *Refresher) RefreshAll() RefreshResult {
+	start := time.Now()
+	itemCodes := r.cache.itemCodes()
+	batches := make(chan []string)
+	result := &RefreshResult{}
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				err := r.refreshBatch(batch)
+				mu.Lock()
+				if err != nil {
+					result.Failed += len(batch)
+				} else {
+					result.Refreshed += len(batch)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < len(itemCodes); i += r.batchSize {
+		end := i + r.batchSize
+		if end > len(itemCodes) {
+			end = len(itemCodes)
+		}
+		batches <- itemCodes[i:end]
+	}
+	close(batches)
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	r.mu.Lock()
+	r.lastResult = result
+	r.mu.Unlock()
+	return *result
+}
+
+// refreshBatch gets the prices for the batch from the actual service and stores them only if all of them succeeded
+func (r *Refresher) refreshBatch(itemCodes []string) error {
+	prices, units, err := r.fetch(itemCodes)
+	if err != nil {
+		return err
+	}
+	for i, itemCode := range itemCodes {
+		r.cache.store(itemCode, prices[i], units[i])
+	}
+	return nil
+}
+
+// fetch gets the prices of the items with their units
+// Batch calls only return bare prices, so they are not used if the service prices in units
+func (r *Refresher) fetch(itemCodes []string) ([]float64, []Unit, error) {
+	_, withUnits := r.cache.actualPriceService.(UnitPricedService)
+	if batchService, ok := r.cache.actualPriceService.(BatchPriceService); ok && !withUnits {
+		prices, err := batchService.GetPricesFor(itemCodes...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("refreshing prices from service : %v", err.Error())
+		}
+		if len(prices) != len(itemCodes) {
+			return nil, nil, fmt.Errorf("refreshing prices from service : got %v prices for %v items", len(prices), len(itemCodes))
+		}
+		return prices, make([]Unit, len(prices)), nil
+	}
+	prices := make([]float64, len(itemCodes))
+	units := make([]Unit, len(itemCodes))
+	for i, itemCode := range itemCodes {
+		price, unit, err := r.cache.fetch(itemCode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("refreshing price from service : %v", err.Error())
+		}
+		prices[i] = price
+		units[i] = unit
+	}
+	return prices, units, nil
+}
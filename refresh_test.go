@@ -0,0 +1,146 @@
+package sample1
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockBatchPriceService records the batches it was called with
+type mockBatchPriceService struct {
+	*mockPriceService
+	batches [][]string
+	mu      *sync.Mutex
+}
+
+func (m *mockBatchPriceService) GetPricesFor(itemCodes ...string) ([]float64, error) {
+	m.mu.Lock()
+	m.batches = append(m.batches, itemCodes)
+	m.mu.Unlock()
+	prices := []float64{}
+	for _, itemCode := range itemCodes {
+		result := m.mockResults[itemCode]
+		if result.err != nil {
+			return nil, result.err
+		}
+		prices = append(prices, result.price)
+	}
+	return prices, nil
+}
+
+// Check that the refresher updates every item using batch calls
+func TestRefresher_RefreshesAllItemsInBatches(t *testing.T) {
+	mockService := &mockBatchPriceService{
+		mockPriceService: &mockPriceService{
+			mockResults: map[string]mockResult{
+				"p1": {price: 5, err: nil},
+				"p2": {price: 7, err: nil},
+				"p3": {price: 9, err: nil},
+			},
+		},
+		mu: &sync.Mutex{},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPricesWithNoErr(t, cache, "p1", "p2", "p3")
+	mockService.mockResults["p2"] = mockResult{price: 8, err: nil}
+
+	result := NewRefresher(cache, time.Minute, 2, 2).RefreshAll()
+	assertInt(t, 3, result.Refreshed, "wrong number of refreshed items")
+	assertInt(t, 2, len(mockService.batches), "wrong number of batches")
+	assertFloat(t, 8, getPriceWithNoErr(t, cache, "p2"), "wrong price returned")
+	assertInt(t, 3, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that a failed refresh leaves the old value in place
+func TestRefresher_KeepsOldValueOnError(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPriceWithNoErr(t, cache, "p1")
+	mockService.mockResults["p1"] = mockResult{price: 0, err: fmt.Errorf("some error")}
+
+	result := NewRefresher(cache, time.Minute, 1, 1).RefreshAll()
+	assertInt(t, 1, result.Failed, "wrong number of failed items")
+	assertFloat(t, 5, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+}
+
+// Check that the background refresh runs on schedule
+func TestRefresher_RunsInBackground(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPriceWithNoErr(t, cache, "p1")
+	refresher := NewRefresher(cache, 20*time.Millisecond, 1, 1)
+	refresher.Start()
+	time.Sleep(70 * time.Millisecond)
+	refresher.Stop()
+	if mockService.getNumCalls() < 3 {
+		t.Error("expected the background refresh to call the service, got", mockService.getNumCalls())
+	}
+	assertInt(t, 1, refresher.LastResult().Refreshed, "wrong number of refreshed items")
+}
+
+// Check that inherited items are not refreshed from the service, they inherit the refreshed price of their ancestor
+func TestRefresher_SkipsInheritedItems(t *testing.T) {
+	mockService := &mockBatchPriceService{
+		mockPriceService: &mockPriceService{
+			mockResults: map[string]mockResult{
+				"SKU-1-RED": {price: 0, err: ErrPriceNotFound},
+				"SKU-1":     {price: 5, err: nil},
+				"SKU-2":     {price: 7, err: nil},
+			},
+		},
+		mu: &sync.Mutex{},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithHierarchy(SeparatorHierarchy{Separator: "-", MinSegments: 2}))
+	getPricesWithNoErr(t, cache, "SKU-1-RED", "SKU-2")
+	mockService.mockResults["SKU-1"] = mockResult{price: 6, err: nil}
+	mockService.mockResults["SKU-2"] = mockResult{price: 8, err: nil}
+
+	result := NewRefresher(cache, time.Minute, 10, 1).RefreshAll()
+	assertInt(t, 2, result.Refreshed, "wrong number of refreshed items")
+	assertInt(t, 0, result.Failed, "wrong number of failed items")
+	assertFloat(t, 8, getPriceWithNoErr(t, cache, "SKU-2"), "wrong price returned")
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "SKU-1-RED"), "wrong inherited price returned")
+}
+
+// mockBatchUnitPriceService has batch calls, which don't return units, and calls with units
+type mockBatchUnitPriceService struct {
+	*mockBatchPriceService
+}
+
+func (m *mockBatchUnitPriceService) GetPriceWithUnitFor(itemCode string) (float64, Unit, error) {
+	price, err := m.GetPriceFor(itemCode)
+	return price, Kilogram, err
+}
+
+// Check that the refresher doesn't use batch calls when the service prices in units, they would lose the unit
+func TestRefresher_KeepsUnitsOfUnitPricedServices(t *testing.T) {
+	mockService := &mockBatchUnitPriceService{
+		mockBatchPriceService: &mockBatchPriceService{
+			mockPriceService: &mockPriceService{
+				mockResults: map[string]mockResult{
+					"p1": {price: 5, err: nil},
+					"p2": {price: 7, err: nil},
+				},
+			},
+			mu: &sync.Mutex{},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPricesWithNoErr(t, cache, "p1", "p2")
+
+	result := NewRefresher(cache, time.Minute, 10, 1).RefreshAll()
+	assertInt(t, 2, result.Refreshed, "wrong number of refreshed items")
+	assertInt(t, 0, len(mockService.batches), "wrong number of batches")
+	if priceItem, _ := cache.cachedItem("p1"); priceItem.unit != Kilogram {
+		t.Error("wrong unit after refresh", priceItem.unit)
+	}
+}
@@ -0,0 +1,108 @@
+package sample1
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrServerClosed is returned by Serve after Close is called
+var ErrServerClosed = errors.New("server closed")
+
+// errLineTooLong is returned by readLine for a line over its limit, the rest of the line is left unread
+var errLineTooLong = errors.New("line too long")
+
+// connServer accepts connections on a listener and serves each one in its own goroutine
+// It is shared by the protocol front-ends on top of the cache
+type connServer struct {
+	handle   func(conn net.Conn)
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	mu       *sync.Mutex
+	wg       *sync.WaitGroup
+}
+
+func newConnServer(handle func(conn net.Conn)) *connServer {
+	return &connServer{
+		handle: handle,
+		conns:  map[net.Conn]struct{}{},
+		mu:     &sync.Mutex{},
+		wg:     &sync.WaitGroup{},
+	}
+}
+
+// Serve accepts connections on the listener until Close is called
+func (s *connServer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Close stops accepting connections, closes the open ones and waits for their handlers to return
+func (s *connServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// readLine reads a line of at most limit bytes without its line terminator
+func readLine(reader *bufio.Reader, limit int) (string, error) {
+	line := []byte{}
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return "", errLineTooLong
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
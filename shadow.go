@@ -0,0 +1,152 @@
+package sample1
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// maxShadowMismatches is the amount of mismatches kept in the report, the rest are only counted
+const maxShadowMismatches = 100
+
+// ShadowMismatch is an item for which the candidate returned a different price than the primary
+type ShadowMismatch struct {
+	ItemCode       string
+	PrimaryPrice   float64
+	CandidatePrice float64
+}
+
+// ShadowReport is the result of comparing the candidate against the primary
+type ShadowReport struct {
+	Compared         int
+	Matches          int
+	MismatchCount    int
+	Mismatches       []ShadowMismatch
+	PrimaryErrors    int
+	CandidateErrors  int
+	Dropped          int           // sampled calls not compared because too many comparisons were in flight
+	PrimaryLatency   time.Duration // total latency of the compared primary calls
+	CandidateLatency time.Duration // total latency of the candidate calls
+}
+
+// AvgLatencyDiff is how much slower (or faster if negative) the candidate was on average
+func (r ShadowReport) AvgLatencyDiff() time.Duration {
+	if r.Compared == 0 {
+		return 0
+	}
+	return (r.CandidateLatency - r.PrimaryLatency) / time.Duration(r.Compared)
+}
+
+// ShadowPriceService is a PriceService that serves from the primary service
+// For a sample of the item codes it also asks the candidate service in the background and records the differences
+type ShadowPriceService struct {
+	primary    PriceService
+	candidate  PriceService
+	sampleRate float64
+	inFlight   chan struct{}
+	report     *ShadowReport
+	mu         *sync.Mutex
+	wg         *sync.WaitGroup
+}
+
+// NewShadowPriceService creates a shadow service comparing sampleRate (from 0 to 1) of the item codes
+// The sample is taken by item code, so the same items are always compared
+// At most maxInFlight comparisons run at the same time, sampled calls over that are dropped so a slow candidate
+// can't pile up goroutines and requests
+func NewShadowPriceService(primary PriceService, candidate PriceService, sampleRate float64, maxInFlight int) *ShadowPriceService {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+	return &ShadowPriceService{
+		primary:    primary,
+		candidate:  candidate,
+		sampleRate: sampleRate,
+		inFlight:   make(chan struct{}, maxInFlight),
+		report:     &ShadowReport{},
+		mu:         &sync.Mutex{},
+		wg:         &sync.WaitGroup{},
+	}
+}
+
+// GetPriceFor gets the price from the primary service, the candidate never affects the result
+func (s *ShadowPriceService) GetPriceFor(itemCode string) (float64, error) {
+	start := time.Now()
+	price, err := s.primary.GetPriceFor(itemCode)
+	latency := time.Since(start)
+	if !sampledByHash(itemCode, s.sampleRate) {
+		return price, err
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+		s.wg.Add(1)
+		go s.compare(itemCode, price, err, latency)
+	default:
+		s.mu.Lock()
+		s.report.Dropped++
+		s.mu.Unlock()
+	}
+	return price, err
+}
+
+// Report returns a copy of the comparisons recorded so far
+func (s *ShadowPriceService) Report() ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := *s.report
+	report.Mismatches = append([]ShadowMismatch{}, s.report.Mismatches...)
+	return report
+}
+
+// Wait waits for the comparisons in progress to finish
+func (s *ShadowPriceService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *ShadowPriceService) compare(itemCode string, primaryPrice float64, primaryErr error, primaryLatency time.Duration) {
+	defer s.wg.Done()
+	start := time.Now()
+	candidatePrice, candidateErr := s.candidate.GetPriceFor(itemCode)
+	candidateLatency := time.Since(start)
+	<-s.inFlight
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if primaryErr != nil {
+		s.report.PrimaryErrors++
+	}
+	if candidateErr != nil {
+		s.report.CandidateErrors++
+	}
+	if primaryErr != nil || candidateErr != nil {
+		return
+	}
+	s.report.Compared++
+	s.report.PrimaryLatency += primaryLatency
+	s.report.CandidateLatency += candidateLatency
+	if primaryPrice == candidatePrice {
+		s.report.Matches++
+		return
+	}
+	s.report.MismatchCount++
+	if len(s.report.Mismatches) < maxShadowMismatches {
+		s.report.Mismatches = append(s.report.Mismatches, ShadowMismatch{
+			ItemCode:       itemCode,
+			PrimaryPrice:   primaryPrice,
+			CandidatePrice: candidatePrice,
+		})
+	}
+}
+
+// sampledByHash tells if the item code is part of a sample of rate (from 0 to 1) of the item codes
+// The sample is taken by hash, so an item code is always in it or never
+func sampledByHash(itemCode string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(itemCode))
+	return float64(h.Sum32())/float64(1<<32) < rate
+}
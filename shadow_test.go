@@ -0,0 +1,85 @@
+package sample1
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Check that the shadow service serves from the primary and records the candidate mismatches
+func TestShadowPriceService_RecordsMismatches(t *testing.T) {
+	primary := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+			"p3": {price: 9, err: nil},
+		},
+	}
+	candidate := &mockPriceService{
+		callDelay: 10 * time.Millisecond,
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 8, err: nil},
+			"p3": {price: 0, err: fmt.Errorf("some error")},
+		},
+	}
+	shadow := NewShadowPriceService(primary, candidate, 1, 10)
+	cache := NewTransparentCache(shadow, time.Minute)
+	assertFloats(t, []float64{5, 7, 9}, getPricesWithNoErr(t, cache, "p1", "p2", "p3"), "wrong price returned")
+	shadow.Wait()
+
+	report := shadow.Report()
+	assertInt(t, 2, report.Compared, "wrong number of comparisons")
+	assertInt(t, 1, report.Matches, "wrong number of matches")
+	assertInt(t, 1, report.MismatchCount, "wrong number of mismatches")
+	assertInt(t, 1, report.CandidateErrors, "wrong number of candidate errors")
+	if len(report.Mismatches) != 1 || report.Mismatches[0].ItemCode != "p2" || report.Mismatches[0].CandidatePrice != 8 {
+		t.Error("wrong mismatches recorded", report.Mismatches)
+	}
+	if report.AvgLatencyDiff() <= 0 {
+		t.Error("expected the candidate to be slower, got", report.AvgLatencyDiff())
+	}
+}
+
+// Check that item codes out of the sample are never sent to the candidate
+func TestShadowPriceService_OnlyComparesSample(t *testing.T) {
+	primary := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	candidate := &mockPriceService{}
+	shadow := NewShadowPriceService(primary, candidate, 0, 10)
+	price, err := shadow.GetPriceFor("p1")
+	if err != nil {
+		t.Fatal("error getting price for p1")
+	}
+	shadow.Wait()
+	assertFloat(t, 5, price, "wrong price returned")
+	assertInt(t, 0, candidate.getNumCalls(), "wrong number of candidate calls")
+}
+
+// Check that comparisons over the in-flight limit are dropped and counted
+func TestShadowPriceService_DropsComparisonsWhenFull(t *testing.T) {
+	primary := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	candidate := &mockPriceService{
+		callDelay: 50 * time.Millisecond,
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	shadow := NewShadowPriceService(primary, candidate, 1, 1)
+	for i := 0; i < 3; i++ {
+		if _, err := shadow.GetPriceFor("p1"); err != nil {
+			t.Fatal("error getting price for p1")
+		}
+	}
+	shadow.Wait()
+	report := shadow.Report()
+	assertInt(t, 1, report.Compared, "wrong number of comparisons")
+	assertInt(t, 2, report.Dropped, "wrong number of dropped comparisons")
+}
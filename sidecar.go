@@ -0,0 +1,263 @@
+package sample1
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+)
+
+// Sidecar protocol, all integers are big endian
+// Request:  op (1 byte), item count (2 bytes), then for each item its code length (2 bytes) and code
+// Response: item count (2 bytes), then for each item a status (1 byte) followed by
+// the price as float64 bits (8 bytes) if it is ok, nothing if the price was not found,
+// or the error length (2 bytes) and message if it failed
+const (
+	sidecarOpGet      byte = 1
+	sidecarOpBatchGet byte = 2
+
+	sidecarStatusOK       byte = 0
+	sidecarStatusError    byte = 1
+	sidecarStatusNotFound byte = 2
+
+	maxSidecarItems = math.MaxUint16
+)
+
+// SidecarServer serves the prices in the cache with a compact binary protocol, meant to be used over a Unix socket
+type SidecarServer struct {
+	*connServer
+	cache *TransparentCache
+}
+
+// NewSidecarServer creates a sidecar front-end for the cache
+// Call Serve with a Unix socket listener, for example net.Listen("unix", path)
+func NewSidecarServer(cache *TransparentCache) *SidecarServer {
+	server := &SidecarServer{cache: cache}
+	server.connServer = newConnServer(server.handle)
+	return server
+}
+
+func (s *SidecarServer) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		op, itemCodes, err := readSidecarRequest(reader)
+		if err != nil {
+			return
+		}
+		if op != sidecarOpGet && op != sidecarOpBatchGet {
+			// the rest of the stream can't be trusted after an unknown op
+			return
+		}
+		priceItems, errs := s.cache.getPriceItems(DefaultCaller, itemCodes)
+		writeSidecarResponse(writer, priceItems, errs)
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func readSidecarRequest(reader *bufio.Reader) (byte, []string, error) {
+	op, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	count, err := readUint16(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	itemCodes := make([]string, count)
+	for i := range itemCodes {
+		if itemCodes[i], err = readSidecarString(reader); err != nil {
+			return 0, nil, err
+		}
+	}
+	return op, itemCodes, nil
+}
+
+func writeSidecarResponse(writer *bufio.Writer, priceItems []*PriceItem, errs []error) {
+	writeUint16(writer, len(priceItems))
+	for i, priceItem := range priceItems {
+		if errors.Is(errs[i], ErrPriceNotFound) {
+			writer.WriteByte(sidecarStatusNotFound)
+			continue
+		}
+		if errs[i] != nil {
+			writer.WriteByte(sidecarStatusError)
+			writeSidecarString(writer, errs[i].Error())
+			continue
+		}
+		writer.WriteByte(sidecarStatusOK)
+		binary.Write(writer, binary.BigEndian, math.Float64bits(priceItem.price))
+	}
+}
+
+func readUint16(reader io.Reader) (int, error) {
+	var n uint16
+	if err := binary.Read(reader, binary.BigEndian, &n); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func writeUint16(writer io.Writer, n int) {
+	binary.Write(writer, binary.BigEndian, uint16(n))
+}
+
+func readSidecarString(reader io.Reader) (string, error) {
+	size, err := readUint16(reader)
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// writeSidecarString writes the string, truncated to the longest length the protocol allows
+func writeSidecarString(writer io.Writer, s string) {
+	if len(s) > math.MaxUint16 {
+		s = s[:math.MaxUint16]
+	}
+	writeUint16(writer, len(s))
+	io.WriteString(writer, s)
+}
+
+// SidecarClient is a PriceService that gets the prices from a SidecarServer
+// Requests are sent one at a time over a single connection
+// After an I/O or protocol error the connection can't be trusted, so it is closed and every later call fails
+// Items the sidecar has no price for return an error wrapping ErrPriceNotFound
+type SidecarClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	broken error // why the connection was closed, nil while it is usable
+	mu     *sync.Mutex
+}
+
+// DialSidecar connects to the sidecar server listening on the Unix socket in path
+func DialSidecar(path string) (*SidecarClient, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to sidecar : %v", err.Error())
+	}
+	return &SidecarClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+		mu:     &sync.Mutex{},
+	}, nil
+}
+
+// GetPriceFor gets the price for the item from the sidecar
+func (c *SidecarClient) GetPriceFor(itemCode string) (float64, error) {
+	prices, err := c.do(sidecarOpGet, []string{itemCode})
+	if err != nil {
+		return 0, err
+	}
+	return prices[0], nil
+}
+
+// GetPricesFor gets the prices for several items in one request, in the same order as the item codes
+// If any of the items failed it returns an error
+func (c *SidecarClient) GetPricesFor(itemCodes ...string) ([]float64, error) {
+	if len(itemCodes) > maxSidecarItems {
+		return nil, fmt.Errorf("getting prices from sidecar : %v items is more than the %v allowed", len(itemCodes), maxSidecarItems)
+	}
+	return c.do(sidecarOpBatchGet, itemCodes)
+}
+
+// Close closes the connection to the sidecar
+func (c *SidecarClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *SidecarClient) do(op byte, itemCodes []string) ([]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.broken != nil {
+		return nil, fmt.Errorf("sidecar connection closed after an error : %v", c.broken.Error())
+	}
+	prices, err := c.exchange(op, itemCodes)
+	if err != nil {
+		c.broken = err
+		c.conn.Close()
+		return nil, err
+	}
+	if firstErr := firstItemError(prices); firstErr != nil {
+		return nil, firstErr
+	}
+	result := make([]float64, len(prices))
+	for i, price := range prices {
+		result[i] = price.price
+	}
+	return result, nil
+}
+
+// sidecarPrice is the answer of the sidecar for one item, err is set if it had no price for it
+type sidecarPrice struct {
+	price float64
+	err   error
+}
+
+// exchange sends the request and reads the whole response, it only returns an error if the connection failed
+// or the response didn't follow the protocol, the errors of each item are in the prices
+func (c *SidecarClient) exchange(op byte, itemCodes []string) ([]sidecarPrice, error) {
+	c.writer.WriteByte(op)
+	writeUint16(c.writer, len(itemCodes))
+	for _, itemCode := range itemCodes {
+		writeSidecarString(c.writer, itemCode)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("sending request to sidecar : %v", err.Error())
+	}
+
+	count, err := readUint16(c.reader)
+	if err != nil {
+		return nil, fmt.Errorf("reading response from sidecar : %v", err.Error())
+	}
+	if count != len(itemCodes) {
+		return nil, fmt.Errorf("reading response from sidecar : got %v prices for %v items", count, len(itemCodes))
+	}
+	prices := make([]sidecarPrice, count)
+	for i := range prices {
+		status, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading response from sidecar : %v", err.Error())
+		}
+		switch status {
+		case sidecarStatusOK:
+			var bits uint64
+			if err := binary.Read(c.reader, binary.BigEndian, &bits); err != nil {
+				return nil, fmt.Errorf("reading response from sidecar : %v", err.Error())
+			}
+			prices[i].price = math.Float64frombits(bits)
+		case sidecarStatusNotFound:
+			prices[i].err = fmt.Errorf("getting price for %v from sidecar : %w", itemCodes[i], ErrPriceNotFound)
+		case sidecarStatusError:
+			msg, err := readSidecarString(c.reader)
+			if err != nil {
+				return nil, fmt.Errorf("reading response from sidecar : %v", err.Error())
+			}
+			prices[i].err = fmt.Errorf("getting price for %v from sidecar : %v", itemCodes[i], msg)
+		default:
+			return nil, fmt.Errorf("reading response from sidecar : unknown status %v", status)
+		}
+	}
+	return prices, nil
+}
+
+func firstItemError(prices []sidecarPrice) error {
+	for _, price := range prices {
+		if price.err != nil {
+			return price.err
+		}
+	}
+	return nil
+}
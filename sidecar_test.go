@@ -0,0 +1,127 @@
+package sample1
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startSidecar(t *testing.T, cache *TransparentCache) *SidecarClient {
+	path := filepath.Join(t.TempDir(), "prices.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("error listening", err)
+	}
+	server := NewSidecarServer(cache)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	client, err := DialSidecar(path)
+	if err != nil {
+		t.Fatal("error connecting", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// Check that the client gets single and batch prices through the sidecar, in order
+func TestSidecarClient_GetsPrices(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7.5, err: nil},
+		},
+	}
+	client := startSidecar(t, NewTransparentCache(mockService, time.Minute))
+
+	price, err := client.GetPriceFor("p1")
+	if err != nil {
+		t.Fatal("error getting price for p1", err)
+	}
+	assertFloat(t, 5, price, "wrong price returned")
+	prices, err := client.GetPricesFor("p2", "p1")
+	if err != nil {
+		t.Fatal("error getting prices", err)
+	}
+	if len(prices) != 2 || prices[0] != 7.5 || prices[1] != 5 {
+		t.Error("wrong prices returned", prices)
+	}
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+}
+
+// Check that the client can be the actual service of another cache and reports errors
+func TestSidecarClient_ChainsCachesAndReturnsErrors(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 0, err: fmt.Errorf("some error")},
+		},
+	}
+	client := startSidecar(t, NewTransparentCache(mockService, time.Minute))
+	local := NewTransparentCache(client, time.Minute)
+	assertFloat(t, 5, getPriceWithNoErr(t, local, "p1"), "wrong price returned")
+	if _, err := local.GetPriceFor("p2"); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if _, err := client.GetPricesFor("p1", "p2"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// Check that a price the sidecar doesn't have is returned as not found, so a chained cache can inherit it
+func TestSidecarClient_ReturnsNotFound(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"SKU-1-RED": {price: 0, err: ErrPriceNotFound},
+			"SKU-1":     {price: 5, err: nil},
+		},
+	}
+	client := startSidecar(t, NewTransparentCache(mockService, time.Minute))
+	if _, err := client.GetPriceFor("SKU-1-RED"); !errors.Is(err, ErrPriceNotFound) {
+		t.Error("expected price not found error, got", err)
+	}
+	local := NewTransparentCache(client, time.Minute, WithHierarchy(SeparatorHierarchy{Separator: "-", MinSegments: 2}))
+	assertFloat(t, 5, getPriceWithNoErr(t, local, "SKU-1-RED"), "wrong inherited price returned")
+}
+
+// Check that the client stops using the connection after a response that doesn't follow the protocol
+func TestSidecarClient_FailsAfterProtocolError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("error listening", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			if _, _, err := readSidecarRequest(reader); err != nil {
+				return
+			}
+			// two prices for a one item request, the second one would be read as the next response
+			writer := bufio.NewWriter(conn)
+			writeSidecarResponse(writer, []*PriceItem{{price: 1}, {price: 2}}, []error{nil, nil})
+			writer.Flush()
+		}
+	}()
+	client, err := DialSidecar(path)
+	if err != nil {
+		t.Fatal("error connecting", err)
+	}
+	defer client.Close()
+
+	if _, err := client.GetPriceFor("p1"); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if _, err := client.GetPriceFor("p1"); err == nil {
+		t.Error("expected error after the protocol error, got nil")
+	}
+}
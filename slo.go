@@ -0,0 +1,200 @@
+package sample1
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sloSlots is the amount of slots the SLO window is split into, older slots are reused as time passes
+const sloSlots = 60
+
+// sloBounds are the upper bounds of the buckets of the served-age and latency distributions
+var sloBounds = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+	10 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute,
+	10 * time.Minute, 30 * time.Minute, time.Hour,
+}
+
+// SLO is what we promise the consumers of the cache
+// Target is the fraction of lookups that must be within MaxServedAge, within MaxLatency and without errors
+type SLO struct {
+	MaxServedAge time.Duration
+	MaxLatency   time.Duration
+	Target       float64
+	Window       time.Duration // the longest window reports can be asked for
+}
+
+// DistributionBucket is the amount of lookups with a value up to UpperBound, the last one has no upper bound (0)
+type DistributionBucket struct {
+	UpperBound time.Duration
+	Count      int
+}
+
+// SLOReport is the state of the SLO over a window
+// A burn rate of 1 means the error budget is being spent exactly at the rate the target allows
+type SLOReport struct {
+	Window              time.Duration
+	Lookups             int
+	Errors              int
+	AgeViolations       int
+	LatencyViolations   int
+	ErrorRate           float64
+	AgeBurnRate         float64
+	LatencyBurnRate     float64
+	ErrorBurnRate       float64
+	AgeDistribution     []DistributionBucket
+	LatencyDistribution []DistributionBucket
+}
+
+// SLOTracker tracks the served-age and latency of the lookups over rolling windows
+type SLOTracker struct {
+	slo        SLO
+	resolution time.Duration
+	slots      []*sloSlot
+	mu         *sync.Mutex
+}
+
+// sloSlot has the counters of the lookups done during resolution, starting at start
+type sloSlot struct {
+	start             time.Time
+	lookups           int
+	served            int
+	errors            int
+	ageViolations     int
+	latencyViolations int
+	ages              []int
+	latencies         []int
+}
+
+// NewSLOTracker creates a tracker for the SLO, able to report on windows up to slo.Window
+func NewSLOTracker(slo SLO) *SLOTracker {
+	resolution := slo.Window / sloSlots
+	if resolution <= 0 {
+		resolution = time.Millisecond
+	}
+	slots := make([]*sloSlot, sloSlots)
+	for i := range slots {
+		slots[i] = &sloSlot{}
+	}
+	return &SLOTracker{
+		slo:        slo,
+		resolution: resolution,
+		slots:      slots,
+		mu:         &sync.Mutex{},
+	}
+}
+
+// WithSLOTracker makes the cache record every lookup in the tracker
+func WithSLOTracker(tracker *SLOTracker) Option {
+	return func(c *TransparentCache) {
+		c.sloTracker = tracker
+	}
+}
+
+// Record records a lookup that served a price of age after latency, or failed with err
+func (t *SLOTracker) Record(age time.Duration, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slot := t.slot(time.Now())
+	slot.lookups++
+	slot.latencies[boundIndex(latency)]++
+	if latency > t.slo.MaxLatency {
+		slot.latencyViolations++
+	}
+	if err != nil {
+		slot.errors++
+		return
+	}
+	slot.served++
+	slot.ages[boundIndex(age)]++
+	if age > t.slo.MaxServedAge {
+		slot.ageViolations++
+	}
+}
+
+// Report aggregates the lookups of the last window, which is capped to the window of the SLO
+func (t *SLOTracker) Report(window time.Duration) SLOReport {
+	if window > t.slo.Window || window <= 0 {
+		window = t.slo.Window
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	served := 0
+	ages := make([]int, len(sloBounds)+1)
+	latencies := make([]int, len(sloBounds)+1)
+	report := SLOReport{Window: window}
+	for _, slot := range t.slots {
+		if slot.lookups == 0 || !slot.start.Add(t.resolution).After(now.Add(-window)) {
+			continue
+		}
+		report.Lookups += slot.lookups
+		report.Errors += slot.errors
+		report.AgeViolations += slot.ageViolations
+		report.LatencyViolations += slot.latencyViolations
+		served += slot.served
+		for i := range ages {
+			ages[i] += slot.ages[i]
+			latencies[i] += slot.latencies[i]
+		}
+	}
+	report.AgeDistribution = distribution(ages)
+	report.LatencyDistribution = distribution(latencies)
+	if report.Lookups == 0 {
+		return report
+	}
+	report.ErrorRate = float64(report.Errors) / float64(report.Lookups)
+	report.LatencyBurnRate = t.burnRate(report.LatencyViolations, report.Lookups)
+	report.ErrorBurnRate = t.burnRate(report.Errors, report.Lookups)
+	report.AgeBurnRate = t.burnRate(report.AgeViolations, served)
+	return report
+}
+
+// burnRate is how fast the violations spend the error budget, 0 without violations
+// A target of 1 has no budget, so any violation burns it at an infinite rate
+func (t *SLOTracker) burnRate(violations int, total int) float64 {
+	if violations == 0 || total == 0 {
+		return 0
+	}
+	budget := 1 - t.slo.Target
+	if budget <= 0 {
+		return math.Inf(1)
+	}
+	return float64(violations) / float64(total) / budget
+}
+
+// slot returns the slot for now, clearing it if it still has the counters of an older period
+func (t *SLOTracker) slot(now time.Time) *sloSlot {
+	start := now.Truncate(t.resolution)
+	slot := t.slots[(start.UnixNano()/int64(t.resolution))%sloSlots]
+	if !slot.start.Equal(start) {
+		*slot = sloSlot{
+			start:     start,
+			ages:      make([]int, len(sloBounds)+1),
+			latencies: make([]int, len(sloBounds)+1),
+		}
+	}
+	return slot
+}
+
+func boundIndex(value time.Duration) int {
+	for i, bound := range sloBounds {
+		if value <= bound {
+			return i
+		}
+	}
+	return len(sloBounds)
+}
+
+func distribution(counts []int) []DistributionBucket {
+	buckets := make([]DistributionBucket, len(counts))
+	for i, count := range counts {
+		buckets[i].Count = count
+		if i < len(sloBounds) {
+			buckets[i].UpperBound = sloBounds[i]
+		}
+	}
+	return buckets
+}
@@ -0,0 +1,67 @@
+package sample1
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// Check that the lookups of the cache are tracked against the SLO
+func TestSLOTracker_TracksCacheLookups(t *testing.T) {
+	mockService := &mockPriceService{
+		callDelay: 30 * time.Millisecond,
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 0, err: fmt.Errorf("some error")},
+		},
+	}
+	tracker := NewSLOTracker(SLO{MaxServedAge: 30 * time.Millisecond, MaxLatency: 20 * time.Millisecond, Target: 0.9, Window: time.Minute})
+	cache := NewTransparentCache(mockService, time.Minute, WithSLOTracker(tracker))
+	getPriceWithNoErr(t, cache, "p1")
+	time.Sleep(40 * time.Millisecond)
+	getPriceWithNoErr(t, cache, "p1")
+	cache.GetPriceFor("p2")
+
+	report := tracker.Report(time.Minute)
+	assertInt(t, 3, report.Lookups, "wrong number of lookups")
+	assertInt(t, 1, report.Errors, "wrong number of errors")
+	assertInt(t, 1, report.AgeViolations, "wrong number of age violations")
+	assertInt(t, 2, report.LatencyViolations, "wrong number of latency violations")
+	if report.ErrorBurnRate < 3.3 || report.ErrorBurnRate > 3.4 {
+		t.Error("wrong error burn rate", report.ErrorBurnRate)
+	}
+	assertAlmostFloat(t, 5, report.AgeBurnRate, "wrong age burn rate")
+	total := 0
+	for _, bucket := range report.LatencyDistribution {
+		total += bucket.Count
+	}
+	assertInt(t, 3, total, "wrong latency distribution")
+}
+
+// Check that lookups out of the window are not reported
+func TestSLOTracker_RollsWindow(t *testing.T) {
+	tracker := NewSLOTracker(SLO{MaxServedAge: time.Second, MaxLatency: time.Second, Target: 0.99, Window: 200 * time.Millisecond})
+	tracker.Record(0, 0, nil)
+	tracker.Record(0, 2*time.Second, nil)
+	report := tracker.Report(0)
+	assertInt(t, 2, report.Lookups, "wrong number of lookups")
+	assertInt(t, 1, report.LatencyViolations, "wrong number of latency violations")
+	time.Sleep(250 * time.Millisecond)
+	assertInt(t, 0, tracker.Report(0).Lookups, "wrong number of lookups")
+}
+
+// Check that a target of 1 reports no burn without violations and an infinite burn with them
+func TestSLOTracker_TargetWithoutBudget(t *testing.T) {
+	tracker := NewSLOTracker(SLO{MaxServedAge: time.Second, MaxLatency: time.Second, Target: 1, Window: time.Minute})
+	tracker.Record(0, 0, nil)
+	report := tracker.Report(time.Minute)
+	assertFloat(t, 0, report.ErrorBurnRate, "wrong error burn rate")
+	assertFloat(t, 0, report.LatencyBurnRate, "wrong latency burn rate")
+	assertFloat(t, 0, report.AgeBurnRate, "wrong age burn rate")
+
+	tracker.Record(0, 2*time.Second, nil)
+	if report := tracker.Report(time.Minute); !math.IsInf(report.LatencyBurnRate, 1) {
+		t.Error("expected infinite latency burn rate, got", report.LatencyBurnRate)
+	}
+}
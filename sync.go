@@ -0,0 +1,176 @@
+package sample1
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceChange is a change in the price of an item, Removed means the item doesn't have a price anymore
+// Unit is the unit the price is for, empty if the service doesn't price in units
+type PriceChange struct {
+	ItemCode string
+	Price    float64
+	Unit     Unit
+	Removed  bool
+}
+
+// ChangesSinceService is a service that can list the items whose price changed since a moment
+// It also returns the cursor to use in the next call
+type ChangesSinceService interface {
+	ChangesSince(since time.Time) ([]PriceChange, time.Time, error)
+}
+
+// CursorStore persists the cursor of the last sync, so a restart doesn't have to start over
+type CursorStore interface {
+	LoadCursor() (time.Time, error)
+	SaveCursor(cursor time.Time) error
+}
+
+// FileCursorStore is a CursorStore that saves the cursor in a file
+type FileCursorStore struct {
+	path string
+}
+
+// NewFileCursorStore creates a cursor store in path
+func NewFileCursorStore(path string) *FileCursorStore {
+	return &FileCursorStore{path: path}
+}
+
+// LoadCursor reads the cursor from the file, if the file doesn't exist yet it returns the zero time
+func (s *FileCursorStore) LoadCursor() (time.Time, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading cursor : %v", err.Error())
+	}
+	cursor, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing cursor : %v", err.Error())
+	}
+	return cursor, nil
+}
+
+// SaveCursor writes the cursor to a temporary file and renames it, so a crash never leaves a partial cursor
+func (s *FileCursorStore) SaveCursor(cursor time.Time) error {
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(cursor.Format(time.RFC3339Nano)), 0644); err != nil {
+		return fmt.Errorf("writing cursor : %v", err.Error())
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("writing cursor : %v", err.Error())
+	}
+	return nil
+}
+
+// SyncResult is the outcome of one sync
+type SyncResult struct {
+	Updated     int
+	Invalidated int
+	Cursor      time.Time
+}
+
+// Syncer periodically pulls the price changes from the upstream and applies them to the cache
+// Changed items are updated and removed items are invalidated, so the cache can use a much longer maxAge
+// Items that are not in the cache are ignored, they will be fetched when they are asked for
+type Syncer struct {
+	cache    *TransparentCache
+	service  ChangesSinceService
+	store    CursorStore
+	interval time.Duration
+	cursor   *time.Time
+	lastErr  error
+	mu       *sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewSyncer creates a syncer for the cache that runs every interval, saving its cursor in store
+func NewSyncer(cache *TransparentCache, service ChangesSinceService, store CursorStore, interval time.Duration) *Syncer {
+	return &Syncer{
+		cache:    cache,
+		service:  service,
+		store:    store,
+		interval: interval,
+		mu:       &sync.Mutex{},
+	}
+}
+
+// Start runs the sync in the background every interval until Stop is called
+func (s *Syncer) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_, err := s.Sync()
+				s.mu.Lock()
+				s.lastErr = err
+				s.mu.Unlock()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background sync and waits for the current one to finish
+func (s *Syncer) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// LastError returns the error of the last background sync, if any
+func (s *Syncer) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Sync pulls the changes since the last cursor and applies them to the cache
+// The cursor only moves forward once the changes were applied and saved
+func (s *Syncer) Sync() (SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cursor == nil {
+		cursor, err := s.store.LoadCursor()
+		if err != nil {
+			return SyncResult{}, fmt.Errorf("syncing prices : %v", err.Error())
+		}
+		s.cursor = &cursor
+	}
+	changes, next, err := s.service.ChangesSince(*s.cursor)
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("getting price changes from service : %v", err.Error())
+	}
+	result := SyncResult{Cursor: next}
+	for _, change := range changes {
+		if change.Removed {
+			if s.cache.Invalidate(change.ItemCode) {
+				result.Invalidated++
+			}
+			continue
+		}
+		updated, invalidated := s.cache.update(change.ItemCode, change.Price, change.Unit)
+		if updated {
+			result.Updated++
+		}
+		if invalidated {
+			result.Invalidated++
+		}
+	}
+	if err := s.store.SaveCursor(next); err != nil {
+		return result, fmt.Errorf("syncing prices : %v", err.Error())
+	}
+	s.cursor = &next
+	return result, nil
+}
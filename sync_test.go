@@ -0,0 +1,88 @@
+package sample1
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mockChangesService returns the changes after the given cursor
+type mockChangesService struct {
+	changes []PriceChange
+	cursors []time.Time // cursor each change was made at
+	calls   []time.Time // since of each call
+}
+
+func (m *mockChangesService) ChangesSince(since time.Time) ([]PriceChange, time.Time, error) {
+	m.calls = append(m.calls, since)
+	changes := []PriceChange{}
+	next := since
+	for i, change := range m.changes {
+		if m.cursors[i].After(since) {
+			changes = append(changes, change)
+			next = m.cursors[i]
+		}
+	}
+	return changes, next, nil
+}
+
+// Check that the syncer updates and invalidates cached items and persists its cursor
+func TestSyncer_AppliesChangesAndPersistsCursor(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+			"p2": {price: 7, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Hour)
+	getPricesWithNoErr(t, cache, "p1", "p2")
+
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	changes := &mockChangesService{
+		changes: []PriceChange{
+			{ItemCode: "p1", Price: 6},
+			{ItemCode: "p2", Removed: true},
+			{ItemCode: "p3", Price: 9},
+		},
+		cursors: []time.Time{t0.Add(time.Minute), t0.Add(2 * time.Minute), t0.Add(3 * time.Minute)},
+	}
+	store := NewFileCursorStore(filepath.Join(t.TempDir(), "cursor"))
+	result, err := NewSyncer(cache, changes, store, time.Minute).Sync()
+	if err != nil {
+		t.Fatal("error syncing", err)
+	}
+	assertInt(t, 1, result.Updated, "wrong number of updated items")
+	assertInt(t, 1, result.Invalidated, "wrong number of invalidated items")
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+	assertFloat(t, 7, getPriceWithNoErr(t, cache, "p2"), "wrong price returned")
+	assertInt(t, 3, mockService.getNumCalls(), "wrong number of service calls")
+
+	cursor, err := store.LoadCursor()
+	if err != nil {
+		t.Fatal("error loading cursor", err)
+	}
+	if !cursor.Equal(t0.Add(3 * time.Minute)) {
+		t.Error("wrong cursor saved", cursor)
+	}
+
+	// a new syncer starts from the saved cursor
+	result, err = NewSyncer(cache, changes, store, time.Minute).Sync()
+	if err != nil {
+		t.Fatal("error syncing", err)
+	}
+	assertInt(t, 0, result.Updated, "wrong number of updated items")
+	if !changes.calls[1].Equal(t0.Add(3 * time.Minute)) {
+		t.Error("sync didn't start from the saved cursor", changes.calls[1])
+	}
+}
+
+// Check that a missing cursor file starts from the beginning
+func TestFileCursorStore_LoadsZeroWhenMissing(t *testing.T) {
+	cursor, err := NewFileCursorStore(filepath.Join(t.TempDir(), "cursor")).LoadCursor()
+	if err != nil {
+		t.Fatal("error loading cursor", err)
+	}
+	if !cursor.IsZero() {
+		t.Error("expected zero cursor, got", cursor)
+	}
+}
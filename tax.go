@@ -0,0 +1,97 @@
+package sample1
+
+import "time"
+
+// TaxRateService is a service that returns the tax rate of a jurisdiction, as a fraction (0.21 for 21%)
+type TaxRateService interface {
+	GetTaxRateFor(jurisdiction string) (float64, error)
+}
+
+// Where the tax rate of a TaxedPrice came from
+const (
+	RateFromCache   = "cache"
+	RateFromService = "service"
+)
+
+// TaxedPrice is the price of an item including the tax of a jurisdiction
+type TaxedPrice struct {
+	ItemCode     string
+	Jurisdiction string
+	Net          float64
+	Tax          float64
+	Gross        float64
+	Rate         float64
+	RateSource   string
+	RateAge      time.Duration
+}
+
+// TaxRateCache is a transparent cache for a TaxRateService, keyed by jurisdiction
+// It is a TransparentCache underneath, so it has the same semantics and options
+type TaxRateCache struct {
+	cache *TransparentCache
+}
+
+// NewTaxRateCache creates a cache for the tax rate service
+func NewTaxRateCache(service TaxRateService, maxAge time.Duration, opts ...Option) *TaxRateCache {
+	return &TaxRateCache{
+		cache: NewTransparentCache(&taxRateAdapter{service: service}, maxAge, append([]Option{withItemKind("tax rate")}, opts...)...),
+	}
+}
+
+// GetTaxRateFor gets the tax rate of the jurisdiction, either from the cache or the actual service
+func (c *TaxRateCache) GetTaxRateFor(jurisdiction string) (float64, error) {
+	return c.cache.GetPriceFor(jurisdiction)
+}
+
+// getTaxRate gets the tax rate with where it came from and how old it is
+func (c *TaxRateCache) getTaxRate(jurisdiction string) (float64, string, time.Duration, error) {
+	trace := &LookupTrace{}
+	rateItem, err := c.cache.lookup(DefaultCaller, jurisdiction, trace)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	source := RateFromCache
+	if trace.UpstreamCalled && trace.UpstreamError == nil {
+		source = RateFromService
+	}
+	return rateItem.price, source, time.Since(*rateItem.dateCreated), nil
+}
+
+// taxRateAdapter is the PriceService of the TransparentCache, the jurisdiction is the item code
+type taxRateAdapter struct {
+	service TaxRateService
+}
+
+func (a *taxRateAdapter) GetPriceFor(jurisdiction string) (float64, error) {
+	return a.service.GetTaxRateFor(jurisdiction)
+}
+
+// GetTaxedPriceFor gets the price of the item and the tax rate of the jurisdiction in parallel and applies the tax
+func GetTaxedPriceFor(prices *TransparentCache, rates *TaxRateCache, itemCode string, jurisdiction string) (TaxedPrice, error) {
+	var price float64
+	var priceErr error
+	done := make(chan struct{})
+	go func() {
+		price, priceErr = prices.GetPriceFor(itemCode)
+		close(done)
+	}()
+	rate, source, age, rateErr := rates.getTaxRate(jurisdiction)
+	<-done
+	if priceErr != nil {
+		return TaxedPrice{}, priceErr
+	}
+	if rateErr != nil {
+		return TaxedPrice{}, rateErr
+	}
+	tax := price * rate
+	return TaxedPrice{
+		ItemCode:     itemCode,
+		Jurisdiction: jurisdiction,
+		Net:          price,
+		Tax:          tax,
+		Gross:        price + tax,
+		Rate:         rate,
+		RateSource:   source,
+		RateAge:      age,
+	}, nil
+}
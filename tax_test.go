@@ -0,0 +1,66 @@
+package sample1
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type mockTaxRateService struct {
+	numCalls int
+	rates    map[string]float64
+}
+
+func (m *mockTaxRateService) GetTaxRateFor(jurisdiction string) (float64, error) {
+	m.numCalls++
+	rate, ok := m.rates[jurisdiction]
+	if !ok {
+		return 0, fmt.Errorf("unknown jurisdiction %v", jurisdiction)
+	}
+	return rate, nil
+}
+
+// Check that the taxed price is computed with a cached rate and reports where the rate came from
+func TestGetTaxedPriceFor_AppliesCachedRate(t *testing.T) {
+	priceService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 100, err: nil},
+		},
+	}
+	rateService := &mockTaxRateService{rates: map[string]float64{"AR": 0.21}}
+	prices := NewTransparentCache(priceService, time.Minute)
+	rates := NewTaxRateCache(rateService, time.Hour)
+
+	taxed, err := GetTaxedPriceFor(prices, rates, "p1", "AR")
+	if err != nil {
+		t.Fatal("error getting taxed price", err)
+	}
+	assertFloat(t, 100, taxed.Net, "wrong net price")
+	assertAlmostFloat(t, 21, taxed.Tax, "wrong tax")
+	assertAlmostFloat(t, 121, taxed.Gross, "wrong gross price")
+	if taxed.RateSource != RateFromService {
+		t.Error("wrong rate source", taxed.RateSource)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	taxed, _ = GetTaxedPriceFor(prices, rates, "p1", "AR")
+	if taxed.RateSource != RateFromCache || taxed.RateAge < 10*time.Millisecond {
+		t.Error("wrong rate source or age", taxed.RateSource, taxed.RateAge)
+	}
+	assertInt(t, 1, rateService.numCalls, "wrong number of rate service calls")
+}
+
+// Check that an unknown jurisdiction returns an error
+func TestGetTaxedPriceFor_ReturnsErrorOnRateError(t *testing.T) {
+	priceService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 100, err: nil},
+		},
+	}
+	prices := NewTransparentCache(priceService, time.Minute)
+	rates := NewTaxRateCache(&mockTaxRateService{}, time.Hour)
+	_, err := GetTaxedPriceFor(prices, rates, "p1", "XX")
+	if err == nil || err.Error() != "getting tax rate from service : unknown jurisdiction XX" {
+		t.Error("wrong error returned", err)
+	}
+}
@@ -0,0 +1,108 @@
+package sample1
+
+import "time"
+
+// RefreshMode is how an entry older than the soft TTL is refreshed
+type RefreshMode int
+
+const (
+	// InlineRefresh refreshes the entry in the lookup, serving the old price if the refresh fails
+	// or another lookup is already refreshing it
+	InlineRefresh RefreshMode = iota
+	// BackgroundRefresh serves the old price and refreshes the entry in a goroutine
+	BackgroundRefresh
+)
+
+// TTLStats counts how lookups were served according to the soft and hard TTL
+type TTLStats struct {
+	Lookups             int
+	FreshHits           int // younger than the soft TTL
+	SoftHits            int // older than the soft TTL, served while or after refreshing
+	HardExpirations     int // older than maxAge, never served
+	Misses              int // not in the cache
+	InlineRefreshes     int
+	BackgroundRefreshes int
+	RefreshErrors       int
+}
+
+// WithSoftTTL makes entries older than softTTL be refreshed, they are still served until they are older than maxAge
+// maxAge is the hard TTL, entries older than it are never served
+func WithSoftTTL(softTTL time.Duration, mode RefreshMode) Option {
+	return func(c *TransparentCache) {
+		c.softTTL = softTTL
+		c.refreshMode = mode
+	}
+}
+
+// TTLStats returns how the lookups were served so far
+func (c *TransparentCache) TTLStats() TTLStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.ttlStats
+}
+
+// countTTL updates the TTL stats holding the lock
+func (c *TransparentCache) countTTL(update func(stats *TTLStats)) {
+	c.mu.Lock()
+	update(c.ttlStats)
+	c.mu.Unlock()
+}
+
+// isSoftExpired tells if the item has to be refreshed even if it can still be served
+func (c *TransparentCache) isSoftExpired(priceItem *PriceItem) bool {
+	return c.softTTL > 0 && !time.Now().Before(priceItem.dateCreated.Add(c.softTTL))
+}
+
+// serveSoftExpired refreshes an item older than the soft TTL according to the refresh mode
+// It returns the refreshed item, or the old one if the refresh failed or is running in the background
+func (c *TransparentCache) serveSoftExpired(caller string, itemCode string, priceItem *PriceItem, trace *LookupTrace) *PriceItem {
+	if c.refreshMode == BackgroundRefresh {
+		trace.decide(SoftExpiredBackground, "age is over the soft TTL, served from the cache and refreshed in the background")
+		c.mu.Lock()
+		c.ttlStats.SoftHits++
+		if c.refreshing[itemCode] {
+			c.mu.Unlock()
+			trace.rule("a background refresh for the item was already running")
+			return priceItem
+		}
+		c.refreshing[itemCode] = true
+		c.ttlStats.BackgroundRefreshes++
+		c.mu.Unlock()
+		go func() {
+			_, err := c.fillItem(caller, itemCode, nil)
+			c.mu.Lock()
+			delete(c.refreshing, itemCode)
+			if err != nil {
+				c.ttlStats.RefreshErrors++
+			}
+			c.mu.Unlock()
+		}()
+		return priceItem
+	}
+
+	trace.decide(SoftExpiredInline, "age is over the soft TTL, refreshed inline")
+	c.mu.Lock()
+	if c.refreshing[itemCode] {
+		// only one lookup refreshes the item, the others serve the old price instead of calling the service too
+		c.ttlStats.SoftHits++
+		c.mu.Unlock()
+		trace.rule("an inline refresh for the item was already running, served the old price")
+		return priceItem
+	}
+	c.refreshing[itemCode] = true
+	c.ttlStats.InlineRefreshes++
+	c.mu.Unlock()
+	refreshed, err := c.fillItem(caller, itemCode, trace)
+	c.mu.Lock()
+	delete(c.refreshing, itemCode)
+	if err != nil {
+		c.ttlStats.RefreshErrors++
+		c.ttlStats.SoftHits++
+	}
+	c.mu.Unlock()
+	if err != nil {
+		trace.rule("refresh failed, served the old price because it is below maxAge")
+		return priceItem
+	}
+	return refreshed
+}
@@ -0,0 +1,110 @@
+package sample1
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Check that an entry older than the soft TTL is refreshed inline and the old price is served if the refresh fails
+func TestGetPriceFor_SoftTTLInlineRefresh(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithSoftTTL(50*time.Millisecond, InlineRefresh))
+	assertFloat(t, 5, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+	assertFloat(t, 5, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+	time.Sleep(60 * time.Millisecond)
+
+	mockService.mockResults["p1"] = mockResult{price: 6, err: nil}
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+	time.Sleep(60 * time.Millisecond)
+	mockService.mockResults["p1"] = mockResult{price: 0, err: fmt.Errorf("some error")}
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+
+	stats := cache.TTLStats()
+	assertInt(t, 1, stats.Misses, "wrong number of misses")
+	assertInt(t, 1, stats.FreshHits, "wrong number of fresh hits")
+	assertInt(t, 2, stats.InlineRefreshes, "wrong number of inline refreshes")
+	assertInt(t, 1, stats.RefreshErrors, "wrong number of refresh errors")
+	assertInt(t, 1, stats.SoftHits, "wrong number of soft hits")
+}
+
+// Check that concurrent lookups of a soft expired entry refresh it inline only once, the others serve the old price
+func TestGetPriceFor_SoftTTLInlineRefreshIsDeduplicated(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithSoftTTL(50*time.Millisecond, InlineRefresh))
+	getPriceWithNoErr(t, cache, "p1")
+	time.Sleep(60 * time.Millisecond)
+
+	mockService.mockResults["p1"] = mockResult{price: 6, err: nil}
+	mockService.callDelay = 100 * time.Millisecond
+	prices := make(chan float64, 5)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prices <- getPriceWithNoErr(t, cache, "p1")
+		}()
+	}
+	wg.Wait()
+	close(prices)
+
+	refreshed := 0
+	for price := range prices {
+		if price == 6 {
+			refreshed++
+		} else {
+			assertFloat(t, 5, price, "wrong price returned")
+		}
+	}
+	assertInt(t, 1, refreshed, "wrong number of refreshed prices returned")
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+	stats := cache.TTLStats()
+	assertInt(t, 1, stats.InlineRefreshes, "wrong number of inline refreshes")
+	assertInt(t, 4, stats.SoftHits, "wrong number of soft hits")
+}
+
+// Check that an entry older than the soft TTL is served while it is refreshed in the background
+func TestGetPriceFor_SoftTTLBackgroundRefresh(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, time.Minute, WithSoftTTL(50*time.Millisecond, BackgroundRefresh))
+	getPriceWithNoErr(t, cache, "p1")
+	time.Sleep(60 * time.Millisecond)
+
+	mockService.mockResults["p1"] = mockResult{price: 6, err: nil}
+	assertFloat(t, 5, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+	time.Sleep(20 * time.Millisecond)
+	assertFloat(t, 6, getPriceWithNoErr(t, cache, "p1"), "wrong price returned")
+	assertInt(t, 2, mockService.getNumCalls(), "wrong number of service calls")
+	assertInt(t, 1, cache.TTLStats().BackgroundRefreshes, "wrong number of background refreshes")
+}
+
+// Check that an entry older than the hard TTL is never served, even if the refresh fails
+func TestGetPriceFor_HardTTLIsNeverServed(t *testing.T) {
+	mockService := &mockPriceService{
+		mockResults: map[string]mockResult{
+			"p1": {price: 5, err: nil},
+		},
+	}
+	cache := NewTransparentCache(mockService, 50*time.Millisecond, WithSoftTTL(20*time.Millisecond, BackgroundRefresh))
+	getPriceWithNoErr(t, cache, "p1")
+	time.Sleep(60 * time.Millisecond)
+	mockService.mockResults["p1"] = mockResult{price: 0, err: fmt.Errorf("some error")}
+	if _, err := cache.GetPriceFor("p1"); err == nil {
+		t.Error("expected error, got nil")
+	}
+	assertInt(t, 1, cache.TTLStats().HardExpirations, "wrong number of hard expirations")
+}
@@ -0,0 +1,183 @@
+package sample1
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrIncompatibleUnits is returned when there is no conversion rule between two units
+var ErrIncompatibleUnits = errors.New("incompatible units")
+
+// Unit is a unit of measure an item is priced in
+type Unit string
+
+// Common units, packs have a different size for each item so they need item conversion rules
+const (
+	Kilogram Unit = "kg"
+	Gram     Unit = "g"
+	Pound    Unit = "lb"
+	Liter    Unit = "l"
+	Each     Unit = "unit"
+	Dozen    Unit = "dozen"
+	Pack     Unit = "pack"
+)
+
+// UnitPrice is a price together with the unit it is for
+type UnitPrice struct {
+	Price float64
+	Unit  Unit
+}
+
+// UnitPriceService is a service that returns the prices with the unit of measure they are for
+type UnitPriceService interface {
+	GetUnitPriceFor(itemCode string) (UnitPrice, error)
+}
+
+// conversion is a rule saying that one of a unit is factor of another
+type conversion struct {
+	to     Unit
+	factor float64
+}
+
+// UnitConverter converts prices between compatible units
+// Rules can be general (1 kg is 1000 g) or only for an item (1 pack of an item is 6 units)
+// Rules work both ways and can be chained
+type UnitConverter struct {
+	rules     map[Unit][]conversion
+	itemRules map[string]map[Unit][]conversion
+	mu        *sync.Mutex
+}
+
+// NewUnitConverter creates a converter with the rules for the common units of mass and count
+func NewUnitConverter() *UnitConverter {
+	converter := &UnitConverter{
+		rules:     map[Unit][]conversion{},
+		itemRules: map[string]map[Unit][]conversion{},
+		mu:        &sync.Mutex{},
+	}
+	converter.AddConversion(Kilogram, Gram, 1000)
+	converter.AddConversion(Pound, Gram, 453.59237)
+	converter.AddConversion(Dozen, Each, 12)
+	return converter
+}
+
+// AddConversion adds the rule that one from is factor to, for all the items
+func (u *UnitConverter) AddConversion(from Unit, to Unit, factor float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	addConversion(u.rules, from, to, factor)
+}
+
+// AddItemConversion adds the rule that one from is factor to, only for the item
+func (u *UnitConverter) AddItemConversion(itemCode string, from Unit, to Unit, factor float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	rules, ok := u.itemRules[itemCode]
+	if !ok {
+		rules = map[Unit][]conversion{}
+		u.itemRules[itemCode] = rules
+	}
+	addConversion(rules, from, to, factor)
+}
+
+func addConversion(rules map[Unit][]conversion, from Unit, to Unit, factor float64) {
+	rules[from] = append(rules[from], conversion{to: to, factor: factor})
+	rules[to] = append(rules[to], conversion{to: from, factor: 1 / factor})
+}
+
+// Convert converts the price of the item to a price per unit
+func (u *UnitConverter) Convert(itemCode string, price UnitPrice, unit Unit) (UnitPrice, error) {
+	if price.Unit == unit {
+		return price, nil
+	}
+	factor, ok := u.factor(itemCode, price.Unit, unit)
+	if !ok {
+		return UnitPrice{}, fmt.Errorf("converting price of %v from %v to %v : %w", itemCode, price.Unit, unit, ErrIncompatibleUnits)
+	}
+	// one from is factor to, so the price per to is the price per from divided by factor
+	return UnitPrice{Price: price.Price / factor, Unit: unit}, nil
+}
+
+// factor finds how many to are one from, chaining the rules of the item and the general ones
+func (u *UnitConverter) factor(itemCode string, from Unit, to Unit) (float64, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	factors := map[Unit]float64{from: 1}
+	pending := []Unit{from}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		next := append(append([]conversion{}, u.rules[current]...), u.itemRules[itemCode][current]...)
+		for _, rule := range next {
+			if _, seen := factors[rule.to]; seen {
+				continue
+			}
+			factors[rule.to] = factors[current] * rule.factor
+			if rule.to == to {
+				return factors[rule.to], true
+			}
+			pending = append(pending, rule.to)
+		}
+	}
+	return 0, false
+}
+
+// UnitPricedService is a PriceService that can also return the unit of measure the price is for
+// The TransparentCache stores the unit with the price, so both always come from the same call
+type UnitPricedService interface {
+	PriceService
+	GetPriceWithUnitFor(itemCode string) (float64, Unit, error)
+}
+
+// UnitPriceCache caches the prices of a UnitPriceService in a TransparentCache and returns them in any compatible unit
+// The unit is cached with the price, so an item that changes its unit is never converted with the old one
+type UnitPriceCache struct {
+	cache     *TransparentCache
+	converter *UnitConverter
+}
+
+// NewUnitPriceCache creates a cache for the unit price service, converting with the converter
+func NewUnitPriceCache(service UnitPriceService, converter *UnitConverter, maxAge time.Duration, opts ...Option) *UnitPriceCache {
+	return &UnitPriceCache{
+		cache:     NewTransparentCache(&unitPriceAdapter{service: service}, maxAge, opts...),
+		converter: converter,
+	}
+}
+
+// GetUnitPriceFor gets the price of the item in the unit it is priced in
+func (c *UnitPriceCache) GetUnitPriceFor(itemCode string) (UnitPrice, error) {
+	priceItem, err := c.cache.getPriceItem(DefaultCaller, itemCode)
+	if err != nil {
+		return UnitPrice{}, err
+	}
+	return UnitPrice{Price: priceItem.price, Unit: priceItem.unit}, nil
+}
+
+// GetPriceIn gets the price of the item per unit
+func (c *UnitPriceCache) GetPriceIn(itemCode string, unit Unit) (UnitPrice, error) {
+	price, err := c.GetUnitPriceFor(itemCode)
+	if err != nil {
+		return UnitPrice{}, err
+	}
+	return c.converter.Convert(itemCode, price, unit)
+}
+
+// unitPriceAdapter is the PriceService of the TransparentCache, it hands the unit of the prices to the cache
+type unitPriceAdapter struct {
+	service UnitPriceService
+}
+
+func (a *unitPriceAdapter) GetPriceFor(itemCode string) (float64, error) {
+	price, _, err := a.GetPriceWithUnitFor(itemCode)
+	return price, err
+}
+
+func (a *unitPriceAdapter) GetPriceWithUnitFor(itemCode string) (float64, Unit, error) {
+	price, err := a.service.GetUnitPriceFor(itemCode)
+	if err != nil {
+		return 0, "", err
+	}
+	return price.Price, price.Unit, nil
+}
@@ -0,0 +1,154 @@
+package sample1
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type mockUnitPriceService struct {
+	numCalls int
+	prices   map[string]UnitPrice
+}
+
+func (m *mockUnitPriceService) GetUnitPriceFor(itemCode string) (UnitPrice, error) {
+	m.numCalls++
+	price, ok := m.prices[itemCode]
+	if !ok {
+		return UnitPrice{}, errors.New("unknown item")
+	}
+	return price, nil
+}
+
+func assertAlmostFloat(t *testing.T, expected float64, actual float64, msg string) {
+	if math.Abs(expected-actual) > 1e-9 {
+		assertFloat(t, expected, actual, msg)
+	}
+}
+
+// Check that prices are cached and converted to the requested unit
+func TestUnitPriceCache_ConvertsPrices(t *testing.T) {
+	mockService := &mockUnitPriceService{
+		prices: map[string]UnitPrice{
+			"cheese": {Price: 20, Unit: Kilogram},
+			"eggs":   {Price: 3, Unit: Pack},
+		},
+	}
+	converter := NewUnitConverter()
+	converter.AddItemConversion("eggs", Pack, Each, 6)
+	cache := NewUnitPriceCache(mockService, converter, time.Minute)
+
+	price, err := cache.GetPriceIn("cheese", Gram)
+	if err != nil {
+		t.Fatal("error getting price", err)
+	}
+	assertAlmostFloat(t, 0.02, price.Price, "wrong price per gram")
+	if price.Unit != Gram {
+		t.Error("wrong unit returned", price.Unit)
+	}
+	price, _ = cache.GetPriceIn("cheese", Pound)
+	assertAlmostFloat(t, 20*0.45359237, price.Price, "wrong price per pound")
+	price, _ = cache.GetPriceIn("eggs", Dozen)
+	assertAlmostFloat(t, 6, price.Price, "wrong price per dozen")
+	assertInt(t, 2, mockService.numCalls, "wrong number of service calls")
+}
+
+// Check that converting between incompatible units fails
+func TestUnitPriceCache_RejectsIncompatibleUnits(t *testing.T) {
+	mockService := &mockUnitPriceService{
+		prices: map[string]UnitPrice{
+			"cheese": {Price: 20, Unit: Kilogram},
+			"eggs":   {Price: 3, Unit: Pack},
+		},
+	}
+	converter := NewUnitConverter()
+	converter.AddItemConversion("eggs", Pack, Each, 6)
+	cache := NewUnitPriceCache(mockService, converter, time.Minute)
+
+	if _, err := cache.GetPriceIn("cheese", Each); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected incompatible units error, got", err)
+	}
+	// pack rules of one item don't apply to others
+	if _, err := cache.GetPriceIn("cheese", Pack); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected incompatible units error, got", err)
+	}
+}
+
+// Check that the unit is cached with the price, so refreshes and updates never pair a price with another unit
+func TestUnitPriceCache_KeepsUnitWithPrice(t *testing.T) {
+	mockService := &mockUnitPriceService{
+		prices: map[string]UnitPrice{
+			"cheese": {Price: 20, Unit: Kilogram},
+		},
+	}
+	cache := NewUnitPriceCache(mockService, NewUnitConverter(), time.Minute)
+	if _, err := cache.GetUnitPriceFor("cheese"); err != nil {
+		t.Fatal("error getting price", err)
+	}
+
+	// the item is now priced per pound, a refresh gets both the new price and unit
+	mockService.prices["cheese"] = UnitPrice{Price: 9, Unit: Pound}
+	if _, err := cache.cache.Refresh("cheese"); err != nil {
+		t.Fatal("error refreshing price", err)
+	}
+	price, _ := cache.GetUnitPriceFor("cheese")
+	assertFloat(t, 9, price.Price, "wrong refreshed price")
+	if price.Unit != Pound {
+		t.Error("wrong refreshed unit", price.Unit)
+	}
+
+	// an update with its unit stores both
+	cache.cache.update("cheese", 4, Pound)
+	price, _ = cache.GetUnitPriceFor("cheese")
+	assertFloat(t, 4, price.Price, "wrong updated price")
+	if price.Unit != Pound {
+		t.Error("wrong updated unit", price.Unit)
+	}
+
+	// an update without unit can't be paired with the cached unit, so the entry is got again
+	mockService.prices["cheese"] = UnitPrice{Price: 22, Unit: Kilogram}
+	if updated, invalidated := cache.cache.update("cheese", 10, ""); updated || !invalidated {
+		t.Error("expected the entry to be invalidated")
+	}
+	price, _ = cache.GetUnitPriceFor("cheese")
+	assertFloat(t, 22, price.Price, "wrong price after invalidation")
+	if price.Unit != Kilogram {
+		t.Error("wrong unit after invalidation", price.Unit)
+	}
+	assertInt(t, 3, mockService.numCalls, "wrong number of service calls")
+}
+
+// mockAttributedUnitService returns both the attributes and the units of the items
+type mockAttributedUnitService struct {
+	*mockAttributedPriceService
+	units map[string]Unit
+}
+
+func (m *mockAttributedUnitService) GetPriceWithUnitFor(itemCode string) (float64, Unit, error) {
+	price, err := m.GetPriceFor(itemCode)
+	return price, m.units[itemCode], err
+}
+
+// Check that a service returning units and attributes gets both cached
+func TestGetPriceFor_IndexesAttributesOfUnitPricedService(t *testing.T) {
+	mockService := &mockAttributedUnitService{
+		mockAttributedPriceService: &mockAttributedPriceService{
+			mockPriceService: &mockPriceService{
+				mockResults: map[string]mockResult{
+					"cheese": {price: 20, err: nil},
+				},
+			},
+			attributes: map[string]Attributes{"cheese": {"family": "dairy"}},
+		},
+		units: map[string]Unit{"cheese": Kilogram},
+	}
+	cache := NewTransparentCache(mockService, time.Minute)
+	getPriceWithNoErr(t, cache, "cheese")
+	if itemCodes := cache.ItemCodesBy("family", "dairy"); len(itemCodes) != 1 || itemCodes[0] != "cheese" {
+		t.Error("attributes were not indexed", itemCodes)
+	}
+	if priceItem, _ := cache.cachedItem("cheese"); priceItem.unit != Kilogram {
+		t.Error("wrong unit cached", priceItem.unit)
+	}
+}